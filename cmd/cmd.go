@@ -5,6 +5,9 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/openshift/cluster-health-analyzer/cmd/dryrun"
+	"github.com/openshift/cluster-health-analyzer/cmd/replay"
+	"github.com/openshift/cluster-health-analyzer/cmd/report"
 	"github.com/openshift/cluster-health-analyzer/cmd/serve"
 	"github.com/openshift/cluster-health-analyzer/cmd/simulate"
 )
@@ -28,4 +31,7 @@ func Execute() {
 func init() {
 	rootCmd.AddCommand(simulate.SimulateCmd)
 	rootCmd.AddCommand(serve.ServeCmd)
+	rootCmd.AddCommand(replay.ReplayCmd)
+	rootCmd.AddCommand(dryrun.DryRunCmd)
+	rootCmd.AddCommand(report.ExportReportCmd)
 }
@@ -0,0 +1,208 @@
+package dryrun
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+var inputFile string
+
+var DryRunCmd = &cobra.Command{
+	Use:   "dry-run",
+	Short: "Run the processing pipeline against an OpenMetrics file",
+	Long: `Run the processing pipeline against an OpenMetrics file.
+
+The ALERTS series from the file (e.g. as generated by the simulate command)
+are mapped to components and grouped into incidents, the same way as when
+running against Prometheus. The resulting incidents are printed out.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return dryRunFile(inputFile, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	DryRunCmd.Flags().StringVarP(&inputFile, "input", "i", "", "OpenMetrics file with the ALERTS series")
+	must(DryRunCmd.MarkFlagRequired("input"))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func dryRunFile(inputFile string, w io.Writer) error {
+	f, err := os.Open(inputFile)
+	if err != nil {
+		slog.Error("Failed to open the input file", "error", err)
+		return err
+	}
+	defer f.Close()
+
+	alertsRange, err := parseAlertsRange(f)
+	if err != nil {
+		return err
+	}
+	slog.Info("Loaded alerts range", "len", len(alertsRange))
+
+	incidents := dryRun(alertsRange)
+	return printIncidents(w, incidents)
+}
+
+// parseAlertsRange reads the ALERTS series from the OpenMetrics input.
+//
+// As in OpenMetrics, the timestamps are expected in seconds, possibly
+// fractional.
+func parseAlertsRange(r io.Reader) (prom.RangeVector, error) {
+	// The text parser expects the timestamps in milliseconds.
+	input, err := timestampsToMillis(r)
+	if err != nil {
+		slog.Error("Invalid OpenMetrics format", "error", err)
+		return nil, err
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(input)
+	if err != nil {
+		slog.Error("Invalid OpenMetrics format", "error", err)
+		return nil, err
+	}
+
+	alerts, ok := families["ALERTS"]
+	if !ok {
+		return nil, fmt.Errorf("no ALERTS series found in the input")
+	}
+
+	series := make(map[uint64]*prom.Range)
+	var keys []uint64
+	for _, m := range alerts.GetMetric() {
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if state, ok := labels["alertstate"]; ok && state != "firing" {
+			continue
+		}
+
+		key := model.LabelsToSignature(labels)
+		s, ok := series[key]
+		if !ok {
+			s = &prom.Range{Metric: prom.Alert{Name: labels["alertname"], Labels: labels}}
+			series[key] = s
+			keys = append(keys, key)
+		}
+		s.Samples = append(s.Samples, model.SamplePair{
+			Timestamp: model.Time(m.GetTimestampMs()),
+			Value:     model.SampleValue(m.GetGauge().GetValue()),
+		})
+	}
+
+	ret := make(prom.RangeVector, 0, len(series))
+	for _, key := range keys {
+		s := series[key]
+		sort.Slice(s.Samples, func(i, j int) bool {
+			return s.Samples[i].Timestamp.Before(s.Samples[j].Timestamp)
+		})
+		ret = append(ret, *s)
+	}
+
+	step := inferStep(ret)
+	for i := range ret {
+		ret[i].Step = step
+	}
+	return ret, nil
+}
+
+// timestampsToMillis converts the OpenMetrics timestamps in seconds to
+// the milliseconds of the Prometheus text format.
+func timestampsToMillis(r io.Reader) (io.Reader, error) {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			out.WriteString(line + "\n")
+			continue
+		}
+		// The value and the timestamp follow the labels, if any.
+		labelsEnd := strings.LastIndexByte(line, '}') + 1
+		fields := strings.Fields(line[labelsEnd:])
+		if labelsEnd == 0 && len(fields) > 0 {
+			// The metric name without labels.
+			fields = fields[1:]
+		}
+		if len(fields) != 2 {
+			out.WriteString(line + "\n")
+			continue
+		}
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", fields[1], err)
+		}
+		// The timestamp is the last field of the line.
+		line = strings.TrimRight(line, " \t")
+		out.WriteString(line[:len(line)-len(fields[1])])
+		out.WriteString(strconv.FormatInt(int64(math.Round(seconds*1000)), 10) + "\n")
+	}
+	return &out, scanner.Err()
+}
+
+// inferStep returns the smallest distance between consecutive samples,
+// falling back to a minute when there are no consecutive samples.
+func inferStep(rv prom.RangeVector) time.Duration {
+	var step time.Duration
+	for _, r := range rv {
+		for i := 1; i < len(r.Samples); i++ {
+			d := r.Samples[i].Timestamp.Sub(r.Samples[i-1].Timestamp)
+			if d > 0 && (step == 0 || d < step) {
+				step = d
+			}
+		}
+	}
+	if step == 0 {
+		return time.Minute
+	}
+	return step
+}
+
+// dryRun groups the alerts into incidents the same way the processor does.
+func dryRun(alertsRange prom.RangeVector) []processor.Incident {
+	return processor.GroupIncidents(alertsRange, processor.GroupingConfig{})
+}
+
+func printIncidents(w io.Writer, incidents []processor.Incident) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP_ID\tSTART\tEND\tSEVERITY\tCOMPONENTS\tALERTS")
+	for _, incident := range incidents {
+		components := make([]string, 0, len(incident.Components))
+		for _, c := range incident.Components {
+			components = append(components, c.Layer+"/"+c.Component)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			incident.GroupID,
+			incident.Start.Time().UTC().Format(time.RFC3339),
+			incident.End.Time().UTC().Format(time.RFC3339),
+			incident.Severity,
+			strings.Join(components, ","),
+			strings.Join(incident.Alerts, ","))
+	}
+	return tw.Flush()
+}
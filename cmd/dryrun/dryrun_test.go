@@ -0,0 +1,111 @@
+package dryrun
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/cmd/simulate"
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+const input = `# HELP ALERTS Alert status
+# TYPE ALERTS gauge
+ALERTS{alertname="KubePodCrashLooping",namespace="openshift-monitoring",severity="warning",alertstate="firing"} 1 1719792000
+ALERTS{alertname="KubePodCrashLooping",namespace="openshift-monitoring",severity="warning",alertstate="firing"} 1 1719792300
+ALERTS{alertname="KubePodCrashLooping",namespace="openshift-monitoring",severity="warning",alertstate="firing"} 1 1719792600
+ALERTS{alertname="KubeDeploymentReplicasMismatch",namespace="openshift-monitoring",severity="critical",alertstate="firing"} 1 1719792300
+ALERTS{alertname="KubeDeploymentReplicasMismatch",namespace="openshift-monitoring",severity="critical",alertstate="firing"} 1 1719792600
+ALERTS{alertname="KubeDeploymentReplicasMismatch",namespace="openshift-monitoring",severity="critical",alertstate="pending"} 1 1719792000
+ALERTS{alertname="NodeNotReady",namespace="openshift-machine-config-operator",severity="critical",alertstate="firing"} 1 1719806400
+ALERTS{alertname="NodeNotReady",namespace="openshift-machine-config-operator",severity="critical",alertstate="firing"} 1 1719806700
+# EOF`
+
+func TestParseAlertsRange(t *testing.T) {
+	rv, err := parseAlertsRange(strings.NewReader(input))
+	require.NoError(t, err)
+
+	// The pending alert is skipped.
+	require.Len(t, rv, 3)
+	for _, r := range rv {
+		assert.Equal(t, 5*time.Minute, r.Step)
+	}
+	assert.Equal(t, "KubePodCrashLooping", rv[0].Metric.MLabels()["alertname"])
+	require.Len(t, rv[0].Samples, 3)
+	assert.Equal(t, int64(1719792000), rv[0].Samples[0].Timestamp.Unix())
+
+	_, err = parseAlertsRange(strings.NewReader("# TYPE foo gauge\nfoo 1\n# EOF"))
+	assert.Error(t, err)
+}
+
+func TestDryRun(t *testing.T) {
+	rv, err := parseAlertsRange(strings.NewReader(input))
+	require.NoError(t, err)
+
+	incidents := dryRun(rv)
+	require.Len(t, incidents, 2)
+
+	assert.Equal(t, []string{"KubeDeploymentReplicasMismatch", "KubePodCrashLooping"}, incidents[0].Alerts)
+	assert.Equal(t, processor.Critical, incidents[0].Severity)
+	assert.Equal(t, int64(1719792000), incidents[0].Start.Unix())
+	assert.Equal(t, []string{"NodeNotReady"}, incidents[1].Alerts)
+
+	var out bytes.Buffer
+	require.NoError(t, printIncidents(&out, incidents))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "critical")
+	assert.Contains(t, lines[1], "KubeDeploymentReplicasMismatch,KubePodCrashLooping")
+}
+
+// TestParseAlertsRangeFractionalTimestamps tests the timestamps in seconds
+// are parsed with the fractional part.
+func TestParseAlertsRangeFractionalTimestamps(t *testing.T) {
+	rv, err := parseAlertsRange(strings.NewReader(`# TYPE ALERTS gauge
+ALERTS{alertname="Alert1",alertstate="firing"} 1 1719792000.5
+ALERTS{alertname="Alert1",alertstate="firing"} 1.000000 1719792030.25
+# EOF`))
+	require.NoError(t, err)
+	require.Len(t, rv, 1)
+	require.Len(t, rv[0].Samples, 2)
+	assert.Equal(t, model.Time(1719792000500), rv[0].Samples[0].Timestamp)
+	assert.Equal(t, model.Time(1719792030250), rv[0].Samples[1].Timestamp)
+	assert.Equal(t, 29750*time.Millisecond, rv[0].Step)
+}
+
+// TestDryRunSimulatedOutput tests the dry-run of the default output of
+// the simulate command yields a stable number of incidents.
+func TestDryRunSimulatedOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "openmetrics.txt")
+	simulate.SimulateCmd.SetArgs([]string{"--output", output})
+	require.NoError(t, simulate.SimulateCmd.Execute())
+
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	rv, err := parseAlertsRange(f)
+	require.NoError(t, err)
+
+	// The group IDs are random, compare the alerts of the incidents.
+	incidentAlerts := func(incidents []processor.Incident) [][]string {
+		alerts := make([][]string, 0, len(incidents))
+		for _, incident := range incidents {
+			alerts = append(alerts, incident.Alerts)
+		}
+		return alerts
+	}
+	incidents := dryRun(rv)
+	assert.Len(t, incidents, 4)
+	assert.Contains(t, incidentAlerts(incidents), []string{"ClusterOperatorDegraded", "ClusterOperatorDown",
+		"KubeDaemonSetMisScheduled", "KubeDaemonSetRolloutStuck", "KubeNodeNotReady", "KubeNodeUnreachable",
+		"PodDisruptionBudgetAtLimit", "TargetDown"})
+	// The same input is grouped the same way.
+	assert.ElementsMatch(t, incidentAlerts(incidents), incidentAlerts(dryRun(rv)))
+}
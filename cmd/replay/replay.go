@@ -0,0 +1,142 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+var inputFile string
+var outputFile string
+
+var ReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay a captured alert stream through the alerts grouping",
+	Long: `Replay a captured alert stream through the alerts grouping.
+
+The input is a JSON list of timestamped alert snapshots, as they would be
+loaded by the processor in each iteration:
+
+  [{"timestamp": "2024-07-01T00:00:00Z", "alerts": [{"alertname": "...", ...}]}]
+
+The output contains the same snapshots with the group_id assigned to each
+alert, together with the resulting groups.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return replayFile(inputFile, outputFile)
+	},
+}
+
+func init() {
+	ReplayCmd.Flags().StringVarP(&inputFile, "input", "i", "", "JSON file with the captured alert snapshots")
+	ReplayCmd.Flags().StringVarP(&outputFile, "output", "o", "", "output file (defaults to stdout)")
+	must(ReplayCmd.MarkFlagRequired("input"))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Snapshot represents the alerts firing at a specific time.
+type Snapshot struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Alerts    []map[string]string `json:"alerts"`
+}
+
+// Result is the outcome of replaying the snapshots.
+type Result struct {
+	// Snapshots are the input snapshots with group_id assigned to the alerts.
+	Snapshots []Snapshot `json:"snapshots"`
+	// Groups maps the group ids to the alert names in the group.
+	Groups map[string][]string `json:"groups"`
+}
+
+func replayFile(inputFile, outputFile string) error {
+	f, err := os.Open(inputFile)
+	if err != nil {
+		slog.Error("Failed to open the capture file", "error", err)
+		return err
+	}
+	defer f.Close()
+
+	snapshots, err := parseSnapshots(f)
+	if err != nil {
+		return err
+	}
+
+	result := Replay(&processor.GroupsCollection{}, snapshots)
+	slog.Info("Replayed snapshots", "snapshots", len(snapshots), "groups", len(result.Groups))
+
+	w := io.Writer(os.Stdout)
+	if outputFile != "" {
+		out, err := os.Create(outputFile)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		w = out
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+func parseSnapshots(r io.Reader) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshots); err != nil {
+		slog.Error("Invalid capture format", "error", err)
+		return nil, err
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+	return snapshots, nil
+}
+
+// Replay feeds the snapshots through the groups collection in the order
+// of their timestamps, the same way the processor does on each iteration.
+func Replay(gc *processor.GroupsCollection, snapshots []Snapshot) Result {
+	result := Result{
+		Snapshots: make([]Snapshot, 0, len(snapshots)),
+		Groups:    make(map[string][]string),
+	}
+
+	for _, s := range snapshots {
+		alerts := make([]prom.Alert, 0, len(s.Alerts))
+		for _, labels := range s.Alerts {
+			// Copy the labels, as the processing adds the group_id.
+			alertLabels := make(map[string]string, len(labels)+1)
+			for k, v := range labels {
+				alertLabels[k] = v
+			}
+			alerts = append(alerts, prom.Alert{Name: labels["alertname"], Labels: alertLabels})
+		}
+
+		processed := gc.ProcessAlertsBatch(alerts, s.Timestamp)
+		gc.PruneGroups(s.Timestamp)
+
+		grouped := Snapshot{Timestamp: s.Timestamp, Alerts: make([]map[string]string, 0, len(processed))}
+		for _, a := range processed {
+			grouped.Alerts = append(grouped.Alerts, a.Labels)
+
+			groupID := a.Labels["group_id"]
+			if !slices.Contains(result.Groups[groupID], a.Name) {
+				result.Groups[groupID] = append(result.Groups[groupID], a.Name)
+			}
+		}
+		result.Snapshots = append(result.Snapshots, grouped)
+	}
+	return result
+}
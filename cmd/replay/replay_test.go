@@ -0,0 +1,63 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+func TestReplay(t *testing.T) {
+	input := `[
+  {"timestamp": "2024-07-01T01:00:00Z", "alerts": [
+    {"alertname": "Alert3", "namespace": "ns3"}
+  ]},
+  {"timestamp": "2024-07-01T00:00:00Z", "alerts": [
+    {"alertname": "Alert1", "namespace": "ns1"},
+    {"alertname": "Alert2", "namespace": "ns2"}
+  ]},
+  {"timestamp": "2024-07-01T00:05:00Z", "alerts": [
+    {"alertname": "Alert1", "namespace": "ns1"},
+    {"alertname": "Alert2", "namespace": "ns2"}
+  ]},
+  {"timestamp": "2024-07-01T02:00:00Z", "alerts": [
+    {"alertname": "Alert1", "namespace": "ns1"}
+  ]}
+]`
+
+	snapshots, err := parseSnapshots(strings.NewReader(input))
+	require.NoError(t, err)
+
+	result := Replay(&processor.GroupsCollection{}, snapshots)
+	require.Len(t, result.Snapshots, 4)
+
+	groupOf := func(snapshot int, alertname string) string {
+		for _, a := range result.Snapshots[snapshot].Alerts {
+			if a["alertname"] == alertname {
+				return a["group_id"]
+			}
+		}
+		t.Fatalf("alert %s not found in snapshot %d", alertname, snapshot)
+		return ""
+	}
+
+	// Alerts firing together are grouped together and keep their group.
+	group1 := groupOf(0, "Alert1")
+	assert.NotEmpty(t, group1)
+	assert.Equal(t, group1, groupOf(0, "Alert2"))
+	assert.Equal(t, group1, groupOf(1, "Alert1"))
+	assert.Equal(t, group1, groupOf(1, "Alert2"))
+	// An unrelated alert an hour later gets a new group.
+	assert.NotEqual(t, group1, groupOf(2, "Alert3"))
+	// A recurring alert matches its original group.
+	assert.Equal(t, group1, groupOf(3, "Alert1"))
+
+	assert.Len(t, result.Groups, 2)
+	assert.ElementsMatch(t, []string{"Alert1", "Alert2"}, result.Groups[group1])
+
+	// The input snapshots are not modified by the replay.
+	assert.NotContains(t, snapshots[0].Alerts[0], "group_id")
+}
@@ -0,0 +1,202 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+var (
+	promURL       = "http://localhost:9090"
+	promTokenFile string
+	promCAFile    string
+	outputFile    string
+	slas          map[string]string
+	opts          = options{window: 24 * time.Hour, step: time.Minute}
+)
+
+var ExportReportCmd = &cobra.Command{
+	Use:   "export-report",
+	Short: "Export the current incidents to an HTML report",
+	Long: `Export the current incidents to an HTML report.
+
+The alerts history is loaded from Prometheus and grouped into incidents,
+the same way as when the server starts. The incidents still firing are
+rendered into a self-contained HTML file, grouped by severity, with the
+affected components and the alerts of each incident.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		componentSLAs, err := parseSLAs(slas)
+		if err != nil {
+			return err
+		}
+		opts.slas = componentSLAs
+
+		loader, err := prom.NewLoader(promURL, prom.LoaderConfig{
+			// The token is only read from the environment to avoid exposing it
+			// on the command line.
+			Token:     os.Getenv("PROM_TOKEN"),
+			TokenFile: promTokenFile,
+			CAFile:    promCAFile,
+		})
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		if outputFile != "" {
+			out, err := os.Create(outputFile)
+			if err != nil {
+				return err
+			}
+			defer out.Close()
+			w = out
+		}
+		return exportReport(cmd.Context(), loader, opts, time.Now(), w)
+	},
+}
+
+func init() {
+	if value, ok := os.LookupEnv("PROM_URL"); ok {
+		promURL = value
+	}
+	fs := ExportReportCmd.Flags()
+	fs.StringVarP(&promURL, "prom-url", "u", promURL, "URL of the Prometheus server")
+	fs.StringVar(&promTokenFile, "prom-token-file", promTokenFile,
+		"Path to the bearer token for a remote Prometheus (defaults to in-cluster service account)")
+	fs.StringVar(&promCAFile, "prom-ca-file", promCAFile,
+		"Path to the CA bundle for a remote Prometheus (defaults to in-cluster service CA)")
+	fs.DurationVar(&opts.window, "window", opts.window, "Window of the alerts history the incidents are built from")
+	fs.StringVar(&opts.consoleURL, "console-url", opts.consoleURL,
+		"URL of the OpenShift console the alerts are linked to (no links if empty)")
+	fs.StringToStringVar(&slas, "component-slas", nil,
+		"Targets of the time to resolve the incidents per component, flagged in the report when breached; acknowledgement targets are not supported (e.g. etcd=1h,ingress=4h)")
+	fs.StringVarP(&outputFile, "output", "o", "", "output file (defaults to stdout)")
+}
+
+// options of the report.
+type options struct {
+	// Window of the alerts history loaded from Prometheus.
+	window time.Duration
+	// Resolution of the alerts history.
+	step time.Duration
+	// Base URL of the console, for the alert links.
+	consoleURL string
+	// Resolution targets of the incidents, by component.
+	slas map[string]time.Duration
+}
+
+// alertsRangeLoader loads the alerts history.
+type alertsRangeLoader interface {
+	LoadAlertsRange(ctx context.Context, start, end time.Time, step time.Duration) (prom.RangeVector, error)
+}
+
+// exportReport renders the report of the incidents firing at the given time.
+func exportReport(ctx context.Context, loader alertsRangeLoader, opts options, now time.Time, w io.Writer) error {
+	alertsRange, err := loader.LoadAlertsRange(ctx, now.Add(-opts.window), now, opts.step)
+	if err != nil {
+		slog.Error("Failed to load the alerts", "error", err)
+		return err
+	}
+	incidents := processor.GroupIncidents(alertsRange, processor.GroupingConfig{})
+	processor.ComponentSLAs(opts.slas).CheckSLAs(incidents)
+
+	data := reportData{
+		Generated:  now.UTC(),
+		Window:     opts.window,
+		ConsoleURL: strings.TrimSuffix(opts.consoleURL, "/"),
+	}
+	// The last samples are within a step from the end of the range.
+	firingSince := model.TimeFromUnixNano(now.Add(-opts.step).UnixNano())
+	for _, severity := range []processor.HealthValue{processor.Critical, processor.Warning, processor.Healthy} {
+		section := severityIncidents{Severity: severity.String()}
+		for _, incident := range incidents {
+			if incident.Severity == severity && !incident.End.Before(firingSince) {
+				section.Incidents = append(section.Incidents, incident)
+			}
+		}
+		if len(section.Incidents) > 0 {
+			data.Severities = append(data.Severities, section)
+		}
+	}
+	return reportTemplate.Execute(w, data)
+}
+
+// parseSLAs parses the component=duration pairs.
+func parseSLAs(pairs map[string]string) (map[string]time.Duration, error) {
+	ret := make(map[string]time.Duration, len(pairs))
+	for component, v := range pairs {
+		target, err := time.ParseDuration(v)
+		if err != nil || target <= 0 {
+			return nil, fmt.Errorf("invalid SLA %q for component %q: must be a positive duration", v, component)
+		}
+		ret[component] = target
+	}
+	return ret, nil
+}
+
+type reportData struct {
+	Generated  time.Time
+	Window     time.Duration
+	ConsoleURL string
+	Severities []severityIncidents
+}
+
+type severityIncidents struct {
+	Severity  string
+	Incidents []processor.Incident
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time": func(t model.Time) string {
+		return t.Time().UTC().Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Cluster incidents</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.4em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+h2.critical { color: #c9190b; }
+h2.warning { color: #f0ab00; }
+h2.info { color: #2b9af3; }
+.breached { color: #c9190b; }
+ul { margin: 0; padding-left: 1.2em; }
+</style>
+</head>
+<body>
+<h1>Cluster incidents</h1>
+<p>Generated at {{.Generated.Format "2006-01-02T15:04:05Z07:00"}} from the alerts of the last {{.Window}}.</p>
+{{- range .Severities}}
+<h2 class="{{.Severity}}">{{.Severity}} ({{len .Incidents}})</h2>
+<table>
+<tr><th>Incident</th><th>Start</th><th>Components</th><th>Alerts</th></tr>
+{{- range .Incidents}}
+<tr>
+<td>{{.GroupID}}{{if .SLABreached}}<br><strong class="breached">SLA of {{.SLATarget}} breached</strong>{{end}}</td>
+<td>{{time .Start}}</td>
+<td><ul>{{range .Components}}<li>{{.Layer}}/{{.Component}}</li>{{end}}</ul></td>
+<td><ul>{{range .Alerts}}<li>{{if $.ConsoleURL}}<a href="{{$.ConsoleURL}}/monitoring/alerts?name={{.}}">{{.}}</a>{{else}}{{.}}{{end}}</li>{{end}}</ul></td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No incidents are firing.</p>
+{{- end}}
+</body>
+</html>
+`))
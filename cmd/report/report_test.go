@@ -0,0 +1,69 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+type fakeLoader struct {
+	alerts prom.RangeVector
+}
+
+func (l fakeLoader) LoadAlertsRange(context.Context, time.Time, time.Time, time.Duration) (prom.RangeVector, error) {
+	return l.alerts, nil
+}
+
+func alertRange(labels map[string]string, start, end time.Time) prom.Range {
+	var samples []model.SamplePair
+	for t := start; !t.After(end); t = t.Add(time.Minute) {
+		samples = append(samples, model.SamplePair{Timestamp: model.TimeFromUnixNano(t.UnixNano()), Value: 1})
+	}
+	return prom.Range{
+		Metric:  prom.Alert{Name: labels["alertname"], Labels: labels},
+		Samples: samples,
+		Step:    time.Minute,
+	}
+}
+
+func TestExportReport(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	loader := fakeLoader{alerts: prom.RangeVector{
+		alertRange(map[string]string{"alertname": "KubePodCrashLooping", "namespace": "openshift-monitoring",
+			"severity": "warning"}, now.Add(-time.Hour), now),
+		alertRange(map[string]string{"alertname": "KubeDeploymentReplicasMismatch", "namespace": "openshift-monitoring",
+			"severity": "critical"}, now.Add(-50*time.Minute), now),
+		// Resolved hours ago.
+		alertRange(map[string]string{"alertname": "NodeNotReady", "namespace": "openshift-machine-config-operator",
+			"severity": "critical"}, now.Add(-6*time.Hour), now.Add(-5*time.Hour)),
+	}}
+
+	var out bytes.Buffer
+	err := exportReport(context.Background(), loader,
+		options{window: 24 * time.Hour, step: time.Minute, consoleURL: "https://console.example.com/",
+			slas: map[string]time.Duration{"monitoring": 30 * time.Minute}}, now, &out)
+	require.NoError(t, err)
+
+	html := out.String()
+	assert.Contains(t, html, `<h2 class="critical">critical (1)</h2>`)
+	assert.NotContains(t, html, `<h2 class="warning">`)
+	assert.Contains(t, html, "2024-07-01T11:00:00Z")
+	assert.Contains(t, html, `<a href="https://console.example.com/monitoring/alerts?name=KubePodCrashLooping">`)
+	assert.Contains(t, html, ">KubeDeploymentReplicasMismatch</a>")
+	assert.NotContains(t, html, "NodeNotReady")
+	assert.Contains(t, html, "SLA of 30m0s breached")
+}
+
+func TestExportReportNoIncidents(t *testing.T) {
+	var out bytes.Buffer
+	err := exportReport(context.Background(), fakeLoader{}, options{window: time.Hour, step: time.Minute}, time.Now(), &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "No incidents are firing.")
+}
@@ -8,6 +8,7 @@ import (
 	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
 	"github.com/openshift/library-go/pkg/config/configdefaults"
 	"github.com/openshift/library-go/pkg/config/serving"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	utilversion "k8s.io/apiserver/pkg/util/version"
 	"k8s.io/client-go/kubernetes"
@@ -99,5 +100,14 @@ func buildServerConfig(o options) (*genericapiserver.Config, error) {
 	// We will be serving out own `/metrics` endpoint.
 	serverConfig.EnableMetrics = false
 
+	// The incidents stream is kept open, don't time it out.
+	longRunning := serverConfig.LongRunningFunc
+	serverConfig.LongRunningFunc = func(r *http.Request, requestInfo *apirequest.RequestInfo) bool {
+		if r.URL.Path == server.IncidentsStreamPath {
+			return true
+		}
+		return longRunning != nil && longRunning(r, requestInfo)
+	}
+
 	return serverConfig, nil
 }
@@ -1,20 +1,33 @@
 package serve
 
 import (
+	"cmp"
+	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/prometheus/common/model"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
 	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
 	"github.com/openshift/cluster-health-analyzer/pkg/server"
 )
 
+// ownerCacheTTL is the time the resolved pod owners are cached for.
+const ownerCacheTTL = 10 * time.Minute
+
 var ServeCmd = newServeCmd()
 
 func newServeCmd() *cobra.Command {
@@ -24,15 +37,33 @@ func newServeCmd() *cobra.Command {
 		Short: "Start the server",
 		Long:  "Start the server to expose the metrics for the health analyzer",
 		Run: func(cmd *cobra.Command, args []string) {
-			interval := time.Duration(float64(opts.RefreshInterval) * float64(time.Second))
+			cfg, err := opts.processorConfig()
+			if err != nil {
+				log.Fatal("Invalid parameters: ", err)
+			}
+			if closer, ok := cfg.GroupingAuditLog.(io.Closer); ok {
+				defer closer.Close()
+			}
+
 			apiServer, err := buildServer(opts)
 			if err != nil {
 				log.Fatal("Error building a server", err)
 			}
 
-			slog.Info("Parameters", "refresh-interval", interval, "prom-url", opts.PromURL)
+			slog.Info("Parameters", "refresh-interval", cfg.Interval, "prom-url", cfg.PromURL,
+				"rule-group-label", opts.RuleGroupLabel, "time-match-labels", opts.TimeMatchLabels,
+				"max-groups", opts.MaxGroups, "label-weights", opts.LabelWeights)
 
-			server.StartServer(interval, opts.PromURL, apiServer)
+			var reporter *server.ClusterOperatorReporter
+			if opts.ClusterOperatorName != "" {
+				client, err := opts.dynamicClient()
+				if err != nil {
+					log.Fatal("Error creating a client: ", err)
+				}
+				reporter = server.NewClusterOperatorReporter(client, opts.ClusterOperatorName)
+			}
+
+			server.StartServer(cfg, apiServer, reporter)
 		},
 	}
 	cmd.Flags().AddFlagSet(opts.flags())
@@ -45,6 +76,102 @@ type options struct {
 
 	PromURL string
 
+	// Prefix of the names of the exported metrics.
+	MetricsPrefix string
+
+	// Connection settings for a remote Prometheus. When none of them
+	// is set, the in-cluster service account is used.
+	PromToken     string
+	PromTokenFile string
+	PromCAFile    string
+
+	// Timeout of each Prometheus query (0 means no timeout).
+	PromQueryTimeout time.Duration
+
+	// Alert label carrying the PrometheusRule group, used for grouping.
+	RuleGroupLabel string
+
+	// Alert label with a grouping hint set by the rule author.
+	HintLabel string
+
+	// Pairs of alert names matched only with each other.
+	AlertPairs []string
+
+	// Labels restricting the time-based grouping to alerts sharing them.
+	TimeMatchLabels []string
+
+	// Maximum number of groups kept for matching (0 means no limit).
+	MaxGroups int
+
+	// Weights of the labels used for fuzzy matching.
+	LabelWeights map[string]string
+
+	// Group alerts by component instead of namespace.
+	GroupByComponent bool
+
+	// Time an alert can be missing from the history before considered resolved.
+	ResolutionGrace time.Duration
+
+	// Resolution grace overridden per severity, keyed by severity.
+	SeverityResolutionGrace map[string]string
+
+	// Matching windows of the grouping (0 means the default).
+	FuzzyMatchWindow  time.Duration
+	TimeMatchWindow   time.Duration
+	DirectMatchWindow time.Duration
+
+	// Numbers of affected nodes escalating the compute health (0 disables).
+	NodeWarningThreshold  int
+	NodeCriticalThreshold int
+
+	// Clock difference to Prometheus reported as a warning.
+	TimeSkewThreshold time.Duration
+
+	// Path to the file with the alerts excluded from fuzzy matching.
+	NoMatchAlertsFile string
+
+	// Path to the file with the alerts suppression rules.
+	SuppressionRulesFile string
+
+	// Map alerts on pods to the workloads of their owning controllers.
+	ResolveOwners bool
+
+	// Severities of the alerts remapped per namespace, keyed by namespace/severity.
+	SeverityRemap map[string]string
+
+	// File the groups are persisted to across restarts.
+	GroupsStateFile string
+
+	// Interval of pruning the old groups (0 means each refresh).
+	PruneInterval time.Duration
+
+	// Window of the historical alerts creating the groups on start (0 means all).
+	WarmUpWindow time.Duration
+
+	// Path to the file the grouping decisions are appended to.
+	GroupingAuditLogFile string
+
+	// Path to the file with the additional layers of components.
+	LayersFile string
+
+	// Map the cluster operator conditions to the components health.
+	OperatorConditions bool
+
+	// Serve the components the current alerts are mapped to, for debugging.
+	DebugMappings bool
+
+	// Handling of the grouped alerts not mapped to any component.
+	OthersMode string
+
+	// Criticality tiers of the components, by component name.
+	ComponentTiers map[string]int
+
+	// Health map labels ignored when deduplicating.
+	DedupExcludedLabels []string
+
+	// Name of the ClusterOperator reflecting the analyzer status.
+	ClusterOperatorName string
+
 	// Path to the kube-config file.
 	Kubeconfig string
 
@@ -67,13 +194,214 @@ func newOptions() options {
 		promURL = value
 	}
 
+	// The token is only read from the environment to avoid exposing it
+	// on the command line.
+	promToken := os.Getenv("PROM_TOKEN")
+
 	secureServingOptions := genericoptions.NewSecureServingOptions().WithLoopback()
 	secureServingOptions.BindPort = 8443
 
 	return options{
-		RefreshInterval: refreshInterval,
-		PromURL:         promURL,
+		RefreshInterval:   refreshInterval,
+		PromURL:           promURL,
+		PromToken:         promToken,
+		TimeSkewThreshold: 30 * time.Second,
+		OthersMode:        string(processor.OthersKeep),
+	}
+}
+
+// processorConfig builds the processor configuration from the options.
+func (o *options) processorConfig() (processor.ProcessorConfig, error) {
+	labelWeights := make(map[string]float64, len(o.LabelWeights))
+	for k, v := range o.LabelWeights {
+		w, err := strconv.ParseFloat(v, 64)
+		if err != nil || w <= 0 {
+			return processor.ProcessorConfig{}, fmt.Errorf("invalid weight %q for label %q: must be a positive number", v, k)
+		}
+		labelWeights[k] = w
+	}
+
+	name := processor.MetricName(o.MetricsPrefix, processor.ComponentsMapMetric)
+	if !model.IsValidMetricName(model.LabelValue(name)) {
+		return processor.ProcessorConfig{}, fmt.Errorf("invalid metrics prefix %q: results in invalid metric name %q",
+			o.MetricsPrefix, name)
+	}
+
+	severityRemap, err := parseSeverityRemap(o.SeverityRemap)
+	if err != nil {
+		return processor.ProcessorConfig{}, err
+	}
+
+	alertPairs, err := parseAlertPairs(o.AlertPairs)
+	if err != nil {
+		return processor.ProcessorConfig{}, err
+	}
+
+	severityResolutionGrace := make(map[processor.HealthValue]time.Duration, len(o.SeverityResolutionGrace))
+	for k, v := range o.SeverityResolutionGrace {
+		severity, ok := processor.ParseSeverity(k)
+		if !ok {
+			return processor.ProcessorConfig{}, fmt.Errorf("invalid severity %q in severity resolution grace", k)
+		}
+		grace, err := time.ParseDuration(v)
+		if err != nil || grace < 0 {
+			return processor.ProcessorConfig{}, fmt.Errorf("invalid resolution grace %q for severity %q: must be a non-negative duration", v, k)
+		}
+		severityResolutionGrace[severity] = grace
+	}
+
+	var noMatchAlerts []map[string]string
+	if o.NoMatchAlertsFile != "" {
+		noMatchAlerts, err = processor.LoadNoMatchAlerts(o.NoMatchAlertsFile)
+		if err != nil {
+			return processor.ProcessorConfig{}, err
+		}
+	}
+
+	var suppressionRules []processor.SuppressionRule
+	if o.SuppressionRulesFile != "" {
+		suppressionRules, err = processor.LoadSuppressionRules(o.SuppressionRulesFile)
+		if err != nil {
+			return processor.ProcessorConfig{}, err
+		}
+	}
+
+	var layers processor.Layers
+	if o.LayersFile != "" {
+		layers, err = processor.LoadLayers(o.LayersFile)
+		if err != nil {
+			return processor.ProcessorConfig{}, err
+		}
+	}
+
+	othersMode := processor.OthersMode(o.OthersMode)
+	if !slices.Contains([]processor.OthersMode{processor.OthersKeep, processor.OthersSuppress, processor.OthersUnclassified}, othersMode) {
+		return processor.ProcessorConfig{}, fmt.Errorf("invalid others mode %q: must be keep, suppress or unclassified", o.OthersMode)
+	}
+
+	for component, tier := range o.ComponentTiers {
+		if tier < 1 || tier > 3 {
+			return processor.ProcessorConfig{}, fmt.Errorf("invalid tier %d for component %q: must be 1, 2 or 3", tier, component)
+		}
+	}
+
+	var groupingAuditLog io.Writer
+	if o.GroupingAuditLogFile != "" {
+		f, err := os.OpenFile(o.GroupingAuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return processor.ProcessorConfig{}, err
+		}
+		groupingAuditLog = f
+	}
+
+	var ownerResolver *processor.OwnerResolver
+	if o.ResolveOwners {
+		client, err := o.dynamicClient()
+		if err != nil {
+			return processor.ProcessorConfig{}, err
+		}
+		ownerResolver = processor.NewOwnerResolver(client, ownerCacheTTL)
+	}
+
+	return processor.ProcessorConfig{
+		Interval:      time.Duration(float64(o.RefreshInterval) * float64(time.Second)),
+		PromURL:       o.PromURL,
+		MetricsPrefix: o.MetricsPrefix,
+		PromConfig: prom.LoaderConfig{
+			Token:        o.PromToken,
+			TokenFile:    o.PromTokenFile,
+			CAFile:       o.PromCAFile,
+			QueryTimeout: o.PromQueryTimeout,
+		},
+		Grouping: processor.GroupingConfig{
+			RuleGroupLabel:          o.RuleGroupLabel,
+			HintLabel:               o.HintLabel,
+			NoMatchAlerts:           noMatchAlerts,
+			AlertPairs:              alertPairs,
+			TimeMatchLabels:         o.TimeMatchLabels,
+			MaxGroups:               o.MaxGroups,
+			LabelWeights:            labelWeights,
+			ResolutionGrace:         o.ResolutionGrace,
+			SeverityResolutionGrace: severityResolutionGrace,
+			GroupByComponent:        o.GroupByComponent,
+			FuzzyMatchWindow:        o.FuzzyMatchWindow,
+			TimeMatchWindow:         o.TimeMatchWindow,
+			DirectMatchWindow:       o.DirectMatchWindow,
+		},
+		NodeThresholds: processor.NodeThresholds{
+			Warning:  o.NodeWarningThreshold,
+			Critical: o.NodeCriticalThreshold,
+		},
+		TimeSkewThreshold:   o.TimeSkewThreshold,
+		SeverityRemap:       severityRemap,
+		ComponentTiers:      o.ComponentTiers,
+		Layers:              layers,
+		OthersMode:          othersMode,
+		OperatorConditions:  o.OperatorConditions,
+		DebugMappings:       o.DebugMappings,
+		SuppressionRules:    suppressionRules,
+		OwnerResolver:       ownerResolver,
+		GroupingAuditLog:    groupingAuditLog,
+		WarmUpWindow:        o.WarmUpWindow,
+		PruneInterval:       o.PruneInterval,
+		GroupsStateFile:     o.GroupsStateFile,
+		DedupExcludedLabels: o.DedupExcludedLabels,
+	}, nil
+}
+
+// dynamicClient creates a client for the cluster from the kube-config.
+func (o *options) dynamicClient() (dynamic.Interface, error) {
+	kubeConfig, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(kubeConfig)
+}
+
+// parseAlertPairs parses the a=b pairs of alert names.
+//
+// The pairs are symmetric, so they are stored with the names ordered and
+// without duplicates, an alert can be part of any number of pairs.
+func parseAlertPairs(entries []string) ([][2]string, error) {
+	alertPairs := make([][2]string, 0, len(entries))
+	for _, entry := range entries {
+		a, b, ok := strings.Cut(entry, "=")
+		if !ok || a == "" || b == "" {
+			return nil, fmt.Errorf("invalid alert pair %q: expected alert=alert", entry)
+		}
+		pair := [2]string{min(a, b), max(a, b)}
+		if !slices.Contains(alertPairs, pair) {
+			alertPairs = append(alertPairs, pair)
+		}
+	}
+	// Keep the configuration, and so its hash, stable.
+	slices.SortFunc(alertPairs, func(a, b [2]string) int {
+		return cmp.Or(strings.Compare(a[0], b[0]), strings.Compare(a[1], b[1]))
+	})
+	return alertPairs, nil
+}
+
+// parseSeverityRemap parses the namespace/severity=severity pairs.
+func parseSeverityRemap(pairs map[string]string) (processor.SeverityRemap, error) {
+	remap := make(processor.SeverityRemap)
+	for k, v := range pairs {
+		namespace, from, ok := strings.Cut(k, "/")
+		if !ok || namespace == "" {
+			return nil, fmt.Errorf("invalid severity remap %q: expected namespace/severity", k)
+		}
+		if _, ok := processor.ParseSeverity(from); !ok {
+			return nil, fmt.Errorf("invalid severity %q in severity remap %q", from, k)
+		}
+		to, ok := processor.ParseSeverity(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid severity %q for severity remap %q", v, k)
+		}
+		if remap[namespace] == nil {
+			remap[namespace] = make(map[string]processor.HealthValue)
+		}
+		remap[namespace][strings.ToLower(from)] = to
 	}
+	return remap, nil
 }
 
 // flags returns supported cli flags for the options.
@@ -83,6 +411,74 @@ func (o *options) flags() *pflag.FlagSet {
 		"Refresh interval in seconds")
 	fs.StringVarP(&o.PromURL, "prom-url", "u", o.PromURL,
 		"URL of the Prometheus server")
+	fs.StringVar(&o.MetricsPrefix, "metrics-prefix", o.MetricsPrefix,
+		"Prefix of the names of the exported metrics (defaults to "+processor.DefaultMetricsPrefix+")")
+	fs.StringVar(&o.PromTokenFile, "prom-token-file", o.PromTokenFile,
+		"Path to the bearer token for a remote Prometheus (defaults to in-cluster service account)")
+	fs.StringVar(&o.PromCAFile, "prom-ca-file", o.PromCAFile,
+		"Path to the CA bundle for a remote Prometheus (defaults to in-cluster service CA)")
+	fs.DurationVar(&o.PromQueryTimeout, "prom-query-timeout", o.PromQueryTimeout,
+		"Timeout of each query to Prometheus (e.g. 30s, 0 means no timeout)")
+	fs.StringVar(&o.RuleGroupLabel, "rule-group-label", o.RuleGroupLabel,
+		"Alert label with the PrometheusRule group; alerts from the same group are grouped together (disabled if empty)")
+	fs.StringVar(&o.HintLabel, "grouping-hint-label", o.HintLabel,
+		"Alert label with a grouping hint set in the alerting rule (e.g. incident_group); alerts sharing its value are grouped together (disabled if empty)")
+	fs.StringSliceVar(&o.AlertPairs, "alert-pairs", o.AlertPairs,
+		"Pairs of alert names grouped only with each other instead of fuzzy matching, an alert can be in multiple pairs (e.g. APIRemovedInNextReleaseInUse=APIRemovedInNextEUSReleaseInUse)")
+	fs.StringSliceVar(&o.TimeMatchLabels, "time-match-labels", o.TimeMatchLabels,
+		"Labels restricting the time-based grouping to alerts sharing a value of at least one of them (e.g. namespace)")
+	fs.IntVar(&o.MaxGroups, "max-groups", o.MaxGroups,
+		"Maximum number of groups kept for matching, the oldest fuzzy groups are evicted first (0 means no limit)")
+	fs.StringToStringVar(&o.LabelWeights, "label-weights", o.LabelWeights,
+		"Weights of the labels used when choosing between fuzzy matches (e.g. namespace=2,job=0.5)")
+	fs.BoolVar(&o.GroupByComponent, "group-by-component", o.GroupByComponent,
+		"Group alerts by the component they map to instead of their namespace")
+	fs.DurationVar(&o.ResolutionGrace, "resolution-grace", o.ResolutionGrace,
+		"Time an alert can be missing from the history before it's considered resolved (e.g. 2m)")
+	fs.StringToStringVar(&o.SeverityResolutionGrace, "severity-resolution-grace", o.SeverityResolutionGrace,
+		"Resolution grace overridden per alert severity (e.g. critical=10m,info=0s)")
+	fs.DurationVar(&o.FuzzyMatchWindow, "fuzzy-match-window", o.FuzzyMatchWindow,
+		"Time within which alerts are fuzzy-matched to existing groups (defaults to "+processor.DefaultFuzzyMatchWindow.String()+")")
+	fs.DurationVar(&o.TimeMatchWindow, "time-match-window", o.TimeMatchWindow,
+		"Time within which otherwise unrelated alerts are grouped together (defaults to "+processor.DefaultTimeMatchWindow.String()+")")
+	fs.DurationVar(&o.DirectMatchWindow, "direct-match-window", o.DirectMatchWindow,
+		"Time within which a recurring alert is matched to its previous group (defaults to "+processor.DefaultDirectMatchWindow.String()+")")
+	fs.IntVar(&o.NodeWarningThreshold, "node-warning-threshold", o.NodeWarningThreshold,
+		"Number of nodes with alerts escalating the compute health to warning (0 disables)")
+	fs.IntVar(&o.NodeCriticalThreshold, "node-critical-threshold", o.NodeCriticalThreshold,
+		"Number of nodes with alerts escalating the compute health to critical (0 disables)")
+	fs.DurationVar(&o.TimeSkewThreshold, "time-skew-threshold", o.TimeSkewThreshold,
+		"Difference between the local and the Prometheus clock above which a warning is logged")
+	fs.StringVar(&o.NoMatchAlertsFile, "no-match-alerts-file", o.NoMatchAlertsFile,
+		"Path to a YAML file with label sets of alerts excluded from fuzzy matching, replacing the defaults (Watchdog, AlertmanagerReceiversNotConfigured)")
+	fs.StringVar(&o.SuppressionRulesFile, "suppression-rules-file", o.SuppressionRulesFile,
+		"Path to a YAML file with rules dropping matching alerts, optionally during a time of day window")
+	fs.BoolVar(&o.ResolveOwners, "resolve-owners", o.ResolveOwners,
+		"Map alerts on pods to the workloads of their owning controllers (requires read access to pods and workload controllers)")
+	fs.StringToStringVar(&o.SeverityRemap, "severity-remap", o.SeverityRemap,
+		"Severities of the alerts remapped per namespace (e.g. openshift-foo/warning=info)")
+	fs.StringVar(&o.LayersFile, "layers-file", o.LayersFile,
+		"Path to a YAML file with additional layers of components mapped by namespace and ordered after a given layer (e.g. platform-addons after core)")
+	fs.BoolVar(&o.OperatorConditions, "cluster-operator-conditions", o.OperatorConditions,
+		"Map the Available, Degraded and Progressing conditions of the cluster operators to the health of their components")
+	fs.BoolVar(&o.DebugMappings, "debug-mappings", o.DebugMappings,
+		"Serve the components the current alerts are mapped to, with the matched labels, on "+server.MappingsPath)
+	fs.StringVar(&o.OthersMode, "others-mode", o.OthersMode,
+		"Handling of the grouped alerts not mapped to any component: keep them under Others, suppress them, or report them as unclassified")
+	fs.StringToIntVar(&o.ComponentTiers, "component-tiers", o.ComponentTiers,
+		"Criticality tiers of the components; incidents touching tier 1 are critical, tier 2 at least warning (e.g. etcd=1,ingress=2)")
+	fs.StringVar(&o.GroupsStateFile, "groups-state-file", o.GroupsStateFile,
+		"Path to a file the incident groups are persisted to, to reuse them on restart instead of rebuilding them from the history (disabled if empty)")
+	fs.DurationVar(&o.PruneInterval, "prune-interval", o.PruneInterval,
+		"Interval of pruning the groups that can't be matched anymore (e.g. 10m, 0 means on each refresh)")
+	fs.DurationVar(&o.WarmUpWindow, "warm-up-window", o.WarmUpWindow,
+		"Only the historical alerts active within this window before the start create the groups (e.g. 24h, 0 means the whole history)")
+	fs.StringVar(&o.GroupingAuditLogFile, "grouping-audit-log", o.GroupingAuditLogFile,
+		"Path to a file the grouping decisions are appended to as JSON lines (disabled if empty)")
+	fs.StringSliceVar(&o.DedupExcludedLabels, "dedup-excluded-labels", o.DedupExcludedLabels,
+		"Exported health map labels ignored when merging duplicate entries (e.g. src_pod)")
+	fs.StringVar(&o.ClusterOperatorName, "cluster-operator-name", o.ClusterOperatorName,
+		"Name of a ClusterOperator the analyzer reports its own status to (disabled if empty, requires write access to clusteroperators)")
 	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig,
 		"The path to the kubeconfig (defaults to in-cluster config)")
 
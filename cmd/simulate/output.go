@@ -0,0 +1,81 @@
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// seriesWriter writes the generated series to the output.
+type seriesWriter interface {
+	// writeHeader starts a new metric family.
+	writeHeader(metricName, help string) error
+	// writeSeries writes the samples of the series between start and end.
+	writeSeries(metricName string, labels map[string]string,
+		start, end model.Time, step time.Duration, value float64) error
+	// finish completes the output.
+	finish() error
+}
+
+// openMetricsWriter writes the series in the OpenMetrics format.
+type openMetricsWriter struct {
+	w io.Writer
+}
+
+func (o openMetricsWriter) writeHeader(metricName, help string) error {
+	_, err := fmt.Fprintf(o.w, "# HELP %s %s\n# TYPE %s gauge\n", metricName, help, metricName)
+	return err
+}
+
+func (o openMetricsWriter) writeSeries(metricName string, labels map[string]string,
+	start, end model.Time, step time.Duration, value float64) error {
+	return fmtInterval(o.w, metricName, labels, start, end, step, value)
+}
+
+func (o openMetricsWriter) finish() error {
+	_, err := fmt.Fprint(o.w, "# EOF")
+	return err
+}
+
+// jsonlSample is a single sample in the JSON lines output.
+type jsonlSample struct {
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels"`
+	Value     float64           `json:"value"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// jsonlWriter writes the series as JSON lines, one sample per line.
+//
+// The samples are written as they are generated, so the output size
+// is not limited by the memory.
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) jsonlWriter {
+	return jsonlWriter{enc: json.NewEncoder(w)}
+}
+
+func (j jsonlWriter) writeHeader(string, string) error {
+	return nil
+}
+
+func (j jsonlWriter) writeSeries(metricName string, labels map[string]string,
+	start, end model.Time, step time.Duration, value float64) error {
+	sample := jsonlSample{Metric: metricName, Labels: labels, Value: value}
+	for s := start; s <= end; s = s.Add(step) {
+		sample.Timestamp = s.Unix()
+		if err := j.enc.Encode(sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j jsonlWriter) finish() error {
+	return nil
+}
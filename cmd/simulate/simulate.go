@@ -30,18 +30,20 @@ func must(err error) {
 
 var outputFile = "cluster-health-analyzer-openmetrics.txt"
 var scenarioFile string
+var jsonl bool
 
 var SimulateCmd = &cobra.Command{
 	Use:   "simulate",
 	Short: "Generate simulated data in openmetrics format",
 	Run: func(cmd *cobra.Command, args []string) {
-		simulate(outputFile, scenarioFile)
+		simulate(outputFile, scenarioFile, jsonl)
 	},
 }
 
 func init() {
 	SimulateCmd.Flags().StringVarP(&outputFile, "output", "o", outputFile, "output file")
 	SimulateCmd.Flags().StringVarP(&scenarioFile, "scenario", "s", "", "CSV file with the scenario to simulate")
+	SimulateCmd.Flags().BoolVar(&jsonl, "jsonl", false, "write the samples as JSON lines instead of OpenMetrics")
 }
 
 var defaultRelativeIntervals = []utils.RelativeInterval{
@@ -235,21 +237,21 @@ func RelativeIntervalToAbsoluteInterval(ri utils.RelativeInterval, origin model.
 	labels["alertstate"] = "firing"
 
 	return processor.Interval{
-		Start:  origin.Add(time.Duration(float64(ri.Start) * float64(time.Minute))),
-		End:    origin.Add(time.Duration(float64(ri.End) * float64(time.Minute))),
+		Start:  origin.Add(time.Duration(ri.Start * float64(time.Minute))),
+		End:    origin.Add(time.Duration(ri.End * float64(time.Minute))),
 		Metric: prom.LabelSet{Labels: labels},
 	}
 }
 
 func RelativeToAbsoluteIntervals(relIntervals []utils.RelativeInterval, end model.Time) []processor.Interval {
-	maxEnd := 0
+	maxEnd := 0.0
 	for _, relInterval := range relIntervals {
 		if relInterval.End > maxEnd {
 			maxEnd = relInterval.End
 		}
 	}
 
-	absStart := end.Add(time.Duration((float64)(-maxEnd) * float64(time.Minute)))
+	absStart := end.Add(time.Duration(-maxEnd * float64(time.Minute)))
 
 	ret := make([]processor.Interval, len(relIntervals))
 	for i, ri := range relIntervals {
@@ -294,16 +296,21 @@ func parseIntervalsFromCSV(file io.Reader) ([]utils.RelativeInterval, error) {
 			continue
 		}
 
-		start, err := strconv.Atoi(fields[0])
+		start, err := parseOffset(fields[0])
 		if err != nil {
 			slog.Error("Invalid start time", "line", line, "error", err)
-			return nil, err
+			return nil, fmt.Errorf("line %d: column start: %w", line, err)
 		}
 
-		end, err := strconv.Atoi(fields[1])
+		end, err := parseOffset(fields[1])
 		if err != nil {
 			slog.Error("Invalid end time", "line", line, "error", err)
-			return nil, err
+			return nil, fmt.Errorf("line %d: column end: %w", line, err)
+		}
+
+		if end < start {
+			slog.Error("End before start", "line", line, "start", fields[0], "end", fields[1])
+			return nil, fmt.Errorf("line %d: end %q is before start %q", line, fields[1], fields[0])
 		}
 
 		labels := map[string]string{
@@ -335,6 +342,21 @@ func parseIntervalsFromCSV(file io.Reader) ([]utils.RelativeInterval, error) {
 	return intervals, nil
 }
 
+// parseOffset parses the relative offset in minutes.
+//
+// The value is either a number of minutes, possibly fractional or negative
+// (e.g. "1.5", "-10"), or a duration with a unit (e.g. "90s", "-1h30m").
+func parseOffset(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if minutes, err := strconv.ParseFloat(value, 64); err == nil {
+		return minutes, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d.Minutes(), nil
+	}
+	return 0, fmt.Errorf("invalid offset %q: expected minutes (e.g. 1.5) or a duration (e.g. 90s)", value)
+}
+
 func buildAlertIntervals(scenarioFile string) ([]processor.Interval, error) {
 	end := model.TimeFromUnixNano(time.Now().UnixNano())
 	intervals := defaultRelativeIntervals
@@ -348,7 +370,15 @@ func buildAlertIntervals(scenarioFile string) ([]processor.Interval, error) {
 	return RelativeToAbsoluteIntervals(intervals, end), nil
 }
 
+// fmtBatchSize is the size of the buffer the samples are batched in before
+// writing them out.
+const fmtBatchSize = 64 * 1024
+
 // fmtInterval writes the interval to the writer in OpenMetrics format.
+//
+// The labels are sorted by name. The samples share the same series and value,
+// so the common prefix is formatted once and the samples are written out
+// in batches rather than one by one.
 func fmtInterval(
 	w io.Writer,
 	metricName string,
@@ -358,34 +388,43 @@ func fmtInterval(
 	step time.Duration,
 	value float64,
 ) error {
-	sb := new(strings.Builder)
-	fmt.Fprintf(sb, "%s{", metricName)
-	first := true
-	for k, v := range labels {
-		// if k == "alertname" {
-		// 	continue
-		// }
-		if first {
-			first = false
-		} else {
-			fmt.Fprint(sb, ",")
-		}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-		fmt.Fprintf(sb, "%s=\"%s\"", k, v)
+	prefix := append([]byte(metricName), '{')
+	for i, k := range keys {
+		if i > 0 {
+			prefix = append(prefix, ',')
+		}
+		prefix = fmt.Appendf(prefix, "%s=\"%s\"", k, labels[k])
 	}
-	fmt.Fprint(sb, "}")
-	labelsStr := sb.String()
+	prefix = append(prefix, "} "...)
+	prefix = strconv.AppendFloat(prefix, value, 'f', 6, 64)
+	prefix = append(prefix, ' ')
 
+	buf := make([]byte, 0, fmtBatchSize)
 	for s := start; s <= end; s = s.Add(step) {
-		_, err := fmt.Fprintf(w, "%s %f %d\n", labelsStr, value, s.Unix())
-		if err != nil {
-			return err
+		buf = append(buf, prefix...)
+		buf = strconv.AppendInt(buf, s.Unix(), 10)
+		buf = append(buf, '\n')
+		if len(buf) >= fmtBatchSize-len(prefix)-32 {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
 		}
 	}
+	if len(buf) > 0 {
+		_, err := w.Write(buf)
+		return err
+	}
 	return nil
 }
 
-func simulate(outputFile, scenarioFile string) {
+func simulate(outputFile, scenarioFile string, jsonl bool) {
 	// Build sample intervals.
 	intervals, err := buildAlertIntervals(scenarioFile)
 	must(err)
@@ -429,20 +468,23 @@ func simulate(outputFile, scenarioFile string) {
 	w := bufio.NewWriter(f)
 	defer w.Flush()
 
+	var out seriesWriter = openMetricsWriter{w: w}
+	if jsonl {
+		out = newJSONLWriter(w)
+	}
+
 	// Output ALERTS
-	fmt.Fprintln(w, "# HELP ALERTS Alert status")
-	fmt.Fprintln(w, "# TYPE ALERTS gauge")
+	must(out.writeHeader("ALERTS", "Alert status"))
 	for _, i := range intervals {
-		err := fmtInterval(w, "ALERTS", i.Metric.MLabels(), i.Start, i.End, step, 1)
+		err := out.writeSeries("ALERTS", i.Metric.MLabels(), i.Start, i.End, step, 1)
 		must(err)
 	}
 
 	// Output cluster:health:components
-	fmt.Fprintln(w, "# HELP cluster:health:components Cluster health components ranking")
-	fmt.Fprintln(w, "# TYPE cluster:health:components gauge")
+	must(out.writeHeader("cluster:health:components", "Cluster health components ranking"))
 	ranks := processor.BuildComponentRanks()
 	for _, rank := range ranks {
-		err := fmtInterval(w, "cluster:health:components", map[string]string{
+		err := out.writeSeries("cluster:health:components", map[string]string{
 			"layer":     rank.Layer,
 			"component": rank.Component,
 		}, start, end, step, float64(rank.Rank))
@@ -458,8 +500,7 @@ func simulate(outputFile, scenarioFile string) {
 	}
 
 	// Output cluster;health;components:map
-	fmt.Fprintln(w, "# HELP cluster:health:components:map Cluster health components mapping")
-	fmt.Fprintln(w, "# TYPE cluster:health:components:map gauge")
+	must(out.writeHeader("cluster:health:components:map", "Cluster health components mapping"))
 
 	for _, gi := range groupedIntervalsSet {
 		labels := gi.Metric.MLabels()
@@ -471,34 +512,13 @@ func simulate(outputFile, scenarioFile string) {
 
 		// Map alert to component
 		healthMap := processor.MapAlerts([]prom.Alert{alert})[0]
-		err := fmtInterval(w, "cluster:health:components:map", healthMap.Labels(), gi.Start, gi.End, step, float64(healthMap.Health))
+		err := out.writeSeries("cluster:health:components:map", healthMap.Labels(), gi.Start, gi.End, step, float64(healthMap.Health))
 		must(err)
 	}
-	fmt.Fprint(w, "# EOF")
-
-	groups := make(map[string][]processor.GroupedInterval)
-	for _, gi := range groupedIntervalsSet {
-		groups[gi.GroupMatcher.RootGroupID] = append(groups[gi.GroupMatcher.RootGroupID], gi)
-	}
-
-	for _, intervals := range groups {
-		start := intervals[0].Start
-		end := intervals[0].End
-		alerts := make(map[string]struct{})
-
-		for _, interval := range intervals {
-			if interval.Start.Before(start) {
-				start = interval.Start
-			}
-			if interval.End.After(end) {
-				end = interval.End
-			}
-			alertname := interval.Interval.Metric.MLabels()["alertname"]
-			alerts[alertname] = struct{}{}
-		}
-	}
+	must(out.finish())
 
-	slog.Info("Generated incidents", "num", len(groups))
+	incidents := processor.BuildIncidents(groupedIntervalsSet)
+	slog.Info("Generated incidents", "num", len(incidents))
 
 	slog.Info("Openmetrics file saved", "output", outputFile)
 }
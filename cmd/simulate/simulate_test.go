@@ -1,11 +1,23 @@
 package simulate
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
 
 	"github.com/openshift/cluster-health-analyzer/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseIntervalsFromCSV_ValidInput(t *testing.T) {
@@ -71,3 +83,138 @@ func TestParseIntervalsFromCSV_InvalidJSONLabels(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestParseIntervalsFromCSV_FractionalAndDurationOffsets(t *testing.T) {
+	input := `start,end,alertname,namespace,severity,labels
+-1.5,2.25,Watchdog,openshift-monitoring,none,
+90s,1h30m,TargetDown,openshift-monitoring,warning,`
+
+	reader := strings.NewReader(input)
+	result, err := parseIntervalsFromCSV(reader)
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, -1.5, result[0].Start)
+	assert.Equal(t, 2.25, result[0].End)
+	assert.Equal(t, 1.5, result[1].Start)
+	assert.Equal(t, 90.0, result[1].End)
+}
+
+func TestParseIntervalsFromCSV_InvalidOffsetMessage(t *testing.T) {
+	input := `start,end,alertname,namespace,severity,labels
+0,60,Watchdog,openshift-monitoring,warning,
+0,ten,Watchdog,openshift-monitoring,warning,`
+
+	reader := strings.NewReader(input)
+	_, err := parseIntervalsFromCSV(reader)
+
+	assert.EqualError(t, err,
+		`line 3: column end: invalid offset "ten": expected minutes (e.g. 1.5) or a duration (e.g. 90s)`)
+}
+
+func TestParseIntervalsFromCSV_EndBeforeStart(t *testing.T) {
+	input := `start,end,alertname,namespace,severity,labels
+60,0,Watchdog,openshift-monitoring,warning,`
+
+	reader := strings.NewReader(input)
+	_, err := parseIntervalsFromCSV(reader)
+
+	assert.EqualError(t, err, `line 2: end "0" is before start "60"`)
+}
+
+func TestSimulateJSONL(t *testing.T) {
+	dir := t.TempDir()
+	scenario := filepath.Join(dir, "scenario.csv")
+	require.NoError(t, os.WriteFile(scenario, []byte(`start,end,alertname,namespace,severity,labels
+0,10,KubePodCrashLooping,openshift-monitoring,warning,
+0,5,TargetDown,openshift-monitoring,warning,`), 0o600))
+
+	openMetricsOutput := filepath.Join(dir, "output.txt")
+	jsonlOutput := filepath.Join(dir, "output.jsonl")
+	simulate(openMetricsOutput, scenario, false)
+	simulate(jsonlOutput, scenario, true)
+
+	countLines := func(path string, fn func(line string)) {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fn(scanner.Text())
+		}
+		require.NoError(t, scanner.Err())
+	}
+
+	// Every sample line of the OpenMetrics output has its JSON line.
+	samples := 0
+	countLines(openMetricsOutput, func(line string) {
+		if !strings.HasPrefix(line, "#") {
+			samples++
+		}
+	})
+
+	lines := 0
+	alertSamples := 0
+	countLines(jsonlOutput, func(line string) {
+		var sample jsonlSample
+		require.NoError(t, json.Unmarshal([]byte(line), &sample))
+		lines++
+		if sample.Metric == "ALERTS" {
+			alertSamples++
+		}
+	})
+
+	assert.Equal(t, samples, lines)
+	// 3 samples of the first alert and 2 of the second one with the 5m step.
+	assert.Equal(t, 5, alertSamples)
+}
+
+// fmtIntervalPerSample formats the interval one sample at a time, as
+// a reference for the batched fmtInterval.
+func fmtIntervalPerSample(w io.Writer, metricName string, labels map[string]string,
+	start, end model.Time, step time.Duration, value float64) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", k, labels[k]))
+	}
+	series := fmt.Sprintf("%s{%s}", metricName, strings.Join(pairs, ","))
+	for s := start; s <= end; s = s.Add(step) {
+		fmt.Fprintf(w, "%s %f %d\n", series, value, s.Unix())
+	}
+}
+
+// TestFmtIntervalBatched tests the batched output is identical to formatting
+// the samples one by one, including intervals spanning multiple batches.
+func TestFmtIntervalBatched(t *testing.T) {
+	start := model.TimeFromUnixNano(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	labels := map[string]string{"alertname": "KubePodCrashLooping", "namespace": "openshift-monitoring", "severity": "warning"}
+
+	for _, end := range []model.Time{start, start.Add(time.Hour), start.Add(30 * 24 * time.Hour)} {
+		var batched, reference bytes.Buffer
+		require.NoError(t, fmtInterval(&batched, "ALERTS", labels, start, end, time.Minute, 1.5))
+		fmtIntervalPerSample(&reference, "ALERTS", labels, start, end, time.Minute, 1.5)
+		assert.Equal(t, reference.String(), batched.String())
+	}
+}
+
+func BenchmarkFmtInterval(b *testing.B) {
+	start := model.TimeFromUnixNano(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	end := start.Add(15 * 24 * time.Hour)
+	labels := map[string]string{"alertname": "KubePodCrashLooping", "namespace": "openshift-monitoring", "severity": "warning"}
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = fmtInterval(io.Discard, "ALERTS", labels, start, end, time.Minute, 1)
+		}
+	})
+	b.Run("per-sample", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fmtIntervalPerSample(io.Discard, "ALERTS", labels, start, end, time.Minute, 1)
+		}
+	})
+}
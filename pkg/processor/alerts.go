@@ -11,18 +11,24 @@ import (
 
 // MapAlerts maps prometheus alerts to component health maps.
 func MapAlerts(alerts []prom.Alert) []ComponentHealthMap {
+	return Layers(nil).MapAlerts(alerts)
+}
+
+// MapAlerts maps prometheus alerts to component health maps, including
+// the components of the additional layers.
+func (l Layers) MapAlerts(alerts []prom.Alert) []ComponentHealthMap {
 	healthMaps := make([]ComponentHealthMap, 0, len(alerts))
 	for _, alert := range alerts {
-		healthMap := getAlertHealthMap(alert)
+		healthMap := getAlertHealthMap(alert, l)
 		healthMaps = append(healthMaps, healthMap)
 	}
 	return healthMaps
 }
 
 // getAlertHealthMap maps a prometheus alert to a component health map.
-func getAlertHealthMap(a prom.Alert) ComponentHealthMap {
+func getAlertHealthMap(a prom.Alert, layers Layers) ComponentHealthMap {
 	// Check if alert is a node alert
-	layer, component, labels := determineComponent(a)
+	layer, component, labels := determineComponent(a, layers)
 
 	healthMap := ComponentHealthMap{
 		Layer:     layer,
@@ -41,14 +47,49 @@ func getAlertHealthMap(a prom.Alert) ComponentHealthMap {
 // determineComponent determines the component of a prometheus alert.
 //
 // It uses various strategies to determine the component.
-func determineComponent(a prom.Alert) (layer, component string, labels map[string]string) {
+func determineComponent(a prom.Alert, layers Layers) (layer, component string, labels map[string]string) {
+	return evalMatcherFns(alertMatcherFns(layers), a.Labels)
+}
+
+// alertMatcherFns returns the strategies for determining the component
+// of an alert, in the order of their precedence.
+func alertMatcherFns(layers Layers) []componentMatcherFn {
 	// Check if alert is a node alert.
-	return evalMatcherFns([]componentMatcherFn{
+	return []componentMatcherFn{
 		cvoAlertsMatcher,
 		computeMatcher,
+		layers.matcher,
 		coreMatcher,
 		workloadMatcher,
-	}, a.Labels)
+		ownerMatcher,
+	}
+}
+
+// AlertMapping explains the component an alert was mapped to.
+type AlertMapping struct {
+	Alert     map[string]string `json:"alert"`
+	Layer     string            `json:"layer"`
+	Component string            `json:"component"`
+	// MatchedKeys are the labels the component was matched on, empty
+	// when matched by the alert name only or not matched at all.
+	MatchedKeys []string `json:"matched_keys"`
+}
+
+// ExplainMappings returns the components the alerts are mapped to, with
+// the labels used for the matching.
+func (l Layers) ExplainMappings(alerts []prom.Alert) []AlertMapping {
+	fns := alertMatcherFns(l)
+	mappings := make([]AlertMapping, 0, len(alerts))
+	for _, a := range alerts {
+		layer, component, keys := matchComponent(fns, a.Labels)
+		mappings = append(mappings, AlertMapping{
+			Alert:       a.Labels,
+			Layer:       layer,
+			Component:   component,
+			MatchedKeys: keys,
+		})
+	}
+	return mappings
 }
 
 var cvoAlerts = []string{"ClusterOperatorDown", "ClusterOperatorDegraded"}
@@ -92,16 +133,168 @@ func workloadMatcher(labels map[string]string) (layer, comp string, keys []strin
 	return "", "", nil
 }
 
+// ownerMatcher maps the alerts to the workload of their owning controller,
+// when resolved by the OwnerResolver.
+//
+// The owner labels are not returned as keys: they are not part of the alerts
+// in Prometheus, so the exported source labels couldn't be matched with the
+// historical alerts on restart.
+func ownerMatcher(labels map[string]string) (layer, comp string, keys []string) {
+	if name := labels[ownerNameLabel]; name != "" {
+		return "workload", name, nil
+	}
+	return "", "", nil
+}
+
+// NodeThresholds defines the numbers of distinct affected nodes escalating
+// the health of the compute component. Zero disables the escalation.
+type NodeThresholds struct {
+	Warning  int
+	Critical int
+}
+
+// escalateComputeHealth raises the health of the compute health maps based
+// on the number of distinct nodes affected by the alerts.
+//
+// A few node alerts are routine on large clusters, while many nodes being
+// affected at the same time is a serious problem, regardless of the severity
+// of the individual alerts. The health maps are expected to be in the same
+// order as the alerts they were mapped from.
+func escalateComputeHealth(alerts []prom.Alert, healthMaps []ComponentHealthMap, thresholds NodeThresholds) {
+	nodes := make(map[string]struct{})
+	for i, hm := range healthMaps {
+		if hm.Layer != "compute" {
+			continue
+		}
+		if node := alerts[i].Labels["node"]; node != "" {
+			nodes[node] = struct{}{}
+		}
+	}
+
+	var escalated HealthValue
+	switch {
+	case thresholds.Critical > 0 && len(nodes) >= thresholds.Critical:
+		escalated = Critical
+	case thresholds.Warning > 0 && len(nodes) >= thresholds.Warning:
+		escalated = Warning
+	default:
+		return
+	}
+
+	for i := range healthMaps {
+		if healthMaps[i].Layer == "compute" {
+			healthMaps[i].Health = max(healthMaps[i].Health, escalated)
+		}
+	}
+}
+
+// ComponentTiers assigns criticality tiers to the components by their name.
+//
+// Tier 1 are the foundational components: any incident with their warning
+// or critical alerts is critical. Tier 2 components make the incident at
+// least a warning. Other tiers don't affect the severity.
+type ComponentTiers map[string]int
+
+// escalateComponentTiers raises the health of the health maps of the tiered
+// components, regardless of the severity of the alerts.
+//
+// The severity of an incident is the highest health of its health maps,
+// so this escalates the whole incident. Only the maps of the alerts that
+// are not healthy are escalated, so that e.g. the informative alerts and
+// the Progressing condition of an upgrading operator don't raise incidents.
+func escalateComponentTiers(healthMaps []ComponentHealthMap, tiers ComponentTiers) {
+	for i, hm := range healthMaps {
+		if hm.SrcType != Alert || hm.Health == Healthy {
+			continue
+		}
+		switch tiers[hm.Component] {
+		case 1:
+			healthMaps[i].Health = Critical
+		case 2:
+			healthMaps[i].Health = max(hm.Health, Warning)
+		}
+	}
+}
+
+// OthersMode configures how the grouped alerts not mapped to any component
+// are reported.
+type OthersMode string
+
+const (
+	// OthersKeep reports them under the Others component, as the default.
+	OthersKeep OthersMode = "keep"
+	// OthersSuppress drops them from the output.
+	OthersSuppress OthersMode = "suppress"
+	// OthersUnclassified reports them under the Unclassified layer and
+	// component, separately from the classified components.
+	OthersUnclassified OthersMode = "unclassified"
+)
+
+// applyOthersMode handles the health maps of the grouped alerts mapped
+// to the Others component.
+func applyOthersMode(healthMaps []ComponentHealthMap, mode OthersMode) []ComponentHealthMap {
+	isGroupedOthers := func(hm ComponentHealthMap) bool {
+		return hm.Component == "Others" && hm.GroupId != ""
+	}
+	switch mode {
+	case OthersSuppress:
+		return slices.DeleteFunc(healthMaps, isGroupedOthers)
+	case OthersUnclassified:
+		for i, hm := range healthMaps {
+			if isGroupedOthers(hm) {
+				healthMaps[i].Layer = "Unclassified"
+				healthMaps[i].Component = "Unclassified"
+			}
+		}
+	}
+	return healthMaps
+}
+
 func updateHealthValue(a prom.Alert, healthMap *ComponentHealthMap) {
-	switch strings.ToLower(a.Labels["severity"]) {
+	health, ok := ParseSeverity(a.Labels["severity"])
+	if !ok {
+		// We don't recognize the severity, so we'll default to warning
+		health = Warning
+	}
+	healthMap.Health = health
+}
+
+// ParseSeverity returns the health value for the alert severity.
+//
+// It returns false when the severity is not recognized.
+func ParseSeverity(severity string) (HealthValue, bool) {
+	switch strings.ToLower(severity) {
 	case "critical":
-		healthMap.Health = Critical
+		return Critical, true
 	case "warning":
-		healthMap.Health = Warning
+		return Warning, true
 	case "info":
-		healthMap.Health = Healthy
+		return Healthy, true
 	default:
-		// We don't recognize the severity, so we'll default to warning
-		healthMap.Health = Warning
+		return Healthy, false
+	}
+}
+
+// SeverityRemap maps the alert severities to health values per namespace,
+// e.g. to downgrade the warnings of a noisy namespace to info.
+//
+// The keys are the namespace and the lower case severity of the alert.
+type SeverityRemap map[string]map[string]HealthValue
+
+// remapSeverities overrides the health of the health maps for the alerts
+// with a remapped severity in their namespace. The health maps are expected
+// to be in the same order as the alerts they were mapped from.
+func remapSeverities(alerts []prom.Alert, healthMaps []ComponentHealthMap, remap SeverityRemap) {
+	if len(remap) == 0 {
+		return
+	}
+	for i, a := range alerts {
+		severities, ok := remap[a.Labels["namespace"]]
+		if !ok {
+			continue
+		}
+		if health, ok := severities[strings.ToLower(a.Labels["severity"])]; ok {
+			healthMaps[i].Health = health
+		}
 	}
 }
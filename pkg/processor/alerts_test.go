@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/openshift/cluster-health-analyzer/pkg/prom"
 )
@@ -31,3 +32,132 @@ func TestAlertsMapAlerts(t *testing.T) {
 	assert.Equal(t, componentsMap[2].Component, "machine-config")
 	assert.Equal(t, componentsMap[1].Layer, "core")
 }
+
+// TestAlertsEscalateComputeHealth tests escalating the compute health based
+// on the number of affected nodes.
+func TestAlertsEscalateComputeHealth(t *testing.T) {
+	nodeAlerts := func(nodes ...string) []prom.Alert {
+		alerts := []prom.Alert{
+			{Name: "KubePodCrashLooping", Labels: map[string]string{
+				"alertname": "KubePodCrashLooping", "namespace": "openshift-etcd", "severity": "info"}},
+		}
+		for _, node := range nodes {
+			alerts = append(alerts, prom.Alert{Name: "KubeNodeNotReady", Labels: map[string]string{
+				"alertname": "KubeNodeNotReady",
+				"namespace": "openshift-monitoring",
+				"severity":  "info",
+				"node":      node}})
+		}
+		return alerts
+	}
+	thresholds := NodeThresholds{Warning: 2, Critical: 4}
+
+	// Few nodes affected: the health is kept.
+	alerts := nodeAlerts("node1", "node1")
+	healthMaps := MapAlerts(alerts)
+	escalateComputeHealth(alerts, healthMaps, thresholds)
+	assert.Equal(t, Healthy, healthMaps[1].Health)
+	assert.Equal(t, Healthy, healthMaps[2].Health)
+
+	// Many nodes affected: the compute health is escalated.
+	alerts = nodeAlerts("node1", "node2", "node3", "node4")
+	healthMaps = MapAlerts(alerts)
+	escalateComputeHealth(alerts, healthMaps, thresholds)
+	for _, hm := range healthMaps[1:] {
+		assert.Equal(t, Critical, hm.Health)
+	}
+	// Other components are not affected.
+	assert.Equal(t, Healthy, healthMaps[0].Health)
+
+	// Warning threshold reached.
+	alerts = nodeAlerts("node1", "node2")
+	healthMaps = MapAlerts(alerts)
+	escalateComputeHealth(alerts, healthMaps, thresholds)
+	assert.Equal(t, Warning, healthMaps[1].Health)
+}
+
+// TestAlertsRemapSeverities tests the severities are remapped only in the
+// configured namespaces.
+func TestAlertsRemapSeverities(t *testing.T) {
+	alerts := []prom.Alert{
+		{Name: "KubePodCrashLooping", Labels: map[string]string{
+			"alertname": "KubePodCrashLooping", "namespace": "team-a", "severity": "warning"}},
+		{Name: "KubePodCrashLooping", Labels: map[string]string{
+			"alertname": "KubePodCrashLooping", "namespace": "team-b", "severity": "warning"}},
+		{Name: "KubePodNotReady", Labels: map[string]string{
+			"alertname": "KubePodNotReady", "namespace": "team-a", "severity": "critical"}},
+	}
+	remap := SeverityRemap{"team-a": {"warning": Healthy}}
+
+	healthMaps := MapAlerts(alerts)
+	remapSeverities(alerts, healthMaps, remap)
+	assert.Equal(t, Healthy, healthMaps[0].Health)
+	assert.Equal(t, Warning, healthMaps[1].Health)
+	// Only the configured severities are remapped.
+	assert.Equal(t, Critical, healthMaps[2].Health)
+}
+
+// TestAlertsEscalateComponentTiers tests the incidents touching the tiered
+// components are escalated.
+func TestAlertsEscalateComponentTiers(t *testing.T) {
+	healthMaps := []ComponentHealthMap{
+		{Layer: "core", Component: "etcd", SrcType: Alert, GroupId: "g1", Health: Warning},
+		{Layer: "core", Component: "ingress", SrcType: Alert, GroupId: "g2", Health: Warning},
+		{Layer: "core", Component: "ingress", SrcType: Alert, GroupId: "g2", Health: Critical},
+		{Layer: "workload", Component: "app", SrcType: Alert, GroupId: "g3", Health: Warning},
+		{Layer: "core", Component: "etcd", SrcType: Alert, GroupId: "g4", Health: Healthy},
+	}
+	escalateComponentTiers(healthMaps, ComponentTiers{"etcd": 1, "ingress": 2, "app": 3})
+
+	// A warning on a tier 1 component yields a critical incident.
+	assert.Equal(t, Critical, healthMaps[0].Health)
+	assert.Equal(t, Warning, healthMaps[1].Health)
+	assert.Equal(t, Critical, healthMaps[2].Health)
+	assert.Equal(t, Warning, healthMaps[3].Health)
+	// The informative alerts are not escalated.
+	assert.Equal(t, Healthy, healthMaps[4].Health)
+}
+
+// TestAlertsEscalateComponentTiersConditions tests the cluster operator
+// conditions of the tiered components are not escalated.
+func TestAlertsEscalateComponentTiersConditions(t *testing.T) {
+	healthMaps := MapClusterOperatorConditions([]prom.Metric{
+		{Labels: map[string]string{"name": "etcd", "condition": "Progressing"}, Value: 1},
+		{Labels: map[string]string{"name": "etcd", "condition": "Degraded"}, Value: 1},
+	})
+	require.Len(t, healthMaps, 2)
+	escalateComponentTiers(healthMaps, ComponentTiers{"etcd": 1})
+
+	// An upgrading tier 1 operator is not an incident.
+	assert.Equal(t, Healthy, healthMaps[0].Health)
+	assert.Equal(t, Warning, healthMaps[1].Health)
+}
+
+// TestAlertsApplyOthersMode tests the grouped alerts mapped to no component
+// are suppressed or routed to the unclassified bucket.
+func TestAlertsApplyOthersMode(t *testing.T) {
+	healthMaps := func() []ComponentHealthMap {
+		return MapAlerts([]prom.Alert{
+			{Name: "CustomAlert", Labels: map[string]string{"alertname": "CustomAlert",
+				"namespace": "custom", "severity": "warning", "group_id": "g1"}},
+			{Name: "etcdNoLeader", Labels: map[string]string{"alertname": "etcdNoLeader",
+				"namespace": "openshift-etcd", "severity": "critical", "group_id": "g2"}},
+		})
+	}
+	require.Equal(t, "Others", healthMaps()[0].Component)
+
+	kept := applyOthersMode(healthMaps(), OthersKeep)
+	assert.Len(t, kept, 2)
+	assert.Equal(t, "Others", kept[0].Component)
+
+	suppressed := applyOthersMode(healthMaps(), OthersSuppress)
+	require.Len(t, suppressed, 1)
+	assert.Equal(t, "etcd", suppressed[0].Component)
+
+	unclassified := applyOthersMode(healthMaps(), OthersUnclassified)
+	require.Len(t, unclassified, 2)
+	assert.Equal(t, "Unclassified", unclassified[0].Layer)
+	assert.Equal(t, "Unclassified", unclassified[0].Component)
+	assert.Equal(t, "g1", unclassified[0].GroupId)
+	assert.Equal(t, "etcd", unclassified[1].Component)
+}
@@ -0,0 +1,91 @@
+package processor
+
+// This file contains logic for recording the grouping decisions.
+
+import (
+	"io"
+	"log/slog"
+	"math"
+)
+
+// Actions recorded in the grouping audit log.
+const (
+	auditGroupCreated  = "group_created"
+	auditAlertAssigned = "alert_assigned"
+	auditGroupRemapped = "group_remapped"
+)
+
+// SetAuditLog records the grouping decisions as JSON lines to the writer.
+//
+// Every processed alert produces an entry, so this is meant for debugging
+// and forensics rather than for permanent use on large clusters. The alerts
+// history processed on start is not recorded.
+func (gc *GroupsCollection) SetAuditLog(w io.Writer) {
+	gc.auditLog = slog.New(slog.NewJSONHandler(w, nil))
+}
+
+func (gc *GroupsCollection) auditGroupCreated(g *GroupMatcher) {
+	if gc.auditLog == nil {
+		return
+	}
+	gc.auditLog.Info(auditGroupCreated,
+		"group_id", g.RootGroupID,
+		"start", g.Start.Time())
+}
+
+// auditAlertAssigned records the group the alert was assigned to, with
+// the labels it was matched on, nil for a new or time-based group.
+func (gc *GroupsCollection) auditAlertAssigned(gi GroupedInterval, matchedLabels map[string]string) {
+	if gc.auditLog == nil {
+		return
+	}
+	labels := gi.Metric.MLabels()
+	gc.auditLog.Info(auditAlertAssigned,
+		"alertname", labels["alertname"],
+		"namespace", labels["namespace"],
+		"group_id", gi.GroupMatcher.RootGroupID,
+		"matched_labels", matchedLabels,
+		auditDistance(gi.GroupMatcher.Distance),
+		"start", gi.Start.Time())
+}
+
+// auditMatchedLabels returns the labels of the interval the group matched
+// on, using the same labels as the matching for the distance of the group.
+//
+// It's only computed when auditing, as it repeats the matching.
+func (gc *GroupsCollection) auditMatchedLabels(i Interval, g *GroupMatcher) map[string]string {
+	if gc.auditLog == nil {
+		return nil
+	}
+	labels := i.Metric.MLabels()
+	switch {
+	case g.Distance == pairMatchDistance:
+		labels = gc.alertPairLabels(labels)
+	case g.Distance >= 2:
+		labels = gc.alertFuzzyLabels(i)
+	}
+	for _, m := range g.Matchers {
+		if matched, keys := m.Matches(labels); matched {
+			return getMapSubset(labels, keys...)
+		}
+	}
+	return nil
+}
+
+func (gc *GroupsCollection) auditGroupRemapped(oldGroupID, newGroupID string) {
+	if gc.auditLog == nil {
+		return
+	}
+	gc.auditLog.Info(auditGroupRemapped,
+		"old_group_id", oldGroupID,
+		"group_id", newGroupID)
+}
+
+// auditDistance returns the distance attribute, with the infinite distance
+// of the root groups as a string, since JSON doesn't support it.
+func auditDistance(d float64) slog.Attr {
+	if math.IsInf(d, 1) {
+		return slog.String("distance", "inf")
+	}
+	return slog.Float64("distance", d)
+}
@@ -0,0 +1,54 @@
+package processor
+
+// This file contains logic for mapping cluster operator conditions to component health maps.
+
+import (
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+// clusterOperatorConditionsQuery selects the conditions of the cluster
+// operators affecting their health.
+var clusterOperatorConditionsQuery = prom.BuildSelectorAny("cluster_operator_conditions", "condition",
+	[]string{"Available", "Degraded", "Progressing"})
+
+// conditionHealth returns the health of the cluster operator condition,
+// false when the condition doesn't affect the health.
+//
+// An unavailable operator is critical, a degraded one is a warning, the
+// progressing one is only informative.
+func conditionHealth(condition string, value float64) (HealthValue, bool) {
+	switch {
+	case condition == "Available" && value == 0:
+		return Critical, true
+	case condition == "Degraded" && value == 1:
+		return Warning, true
+	case condition == "Progressing" && value == 1:
+		return Healthy, true
+	default:
+		return Healthy, false
+	}
+}
+
+// MapClusterOperatorConditions maps the cluster_operator_conditions metrics
+// to the health maps of the core components.
+//
+// As for the cluster-version-operator alerts, the component is the name
+// of the cluster operator. Only the conditions affecting the health are
+// mapped.
+func MapClusterOperatorConditions(conditions []prom.Metric) []ComponentHealthMap {
+	var healthMaps []ComponentHealthMap
+	for _, c := range conditions {
+		health, ok := conditionHealth(c.Labels["condition"], c.Value)
+		if !ok || c.Labels["name"] == "" {
+			continue
+		}
+		healthMaps = append(healthMaps, ComponentHealthMap{
+			Layer:     "core",
+			Component: c.Labels["name"],
+			SrcType:   ClusterOperatorCondition,
+			SrcLabels: getMapSubset(c.Labels, "name", "condition"),
+			Health:    health,
+		})
+	}
+	return healthMaps
+}
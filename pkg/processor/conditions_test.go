@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+// TestConditionHealth tests the mapping of the conditions to the health values.
+func TestConditionHealth(t *testing.T) {
+	tests := []struct {
+		condition string
+		value     float64
+		health    HealthValue
+		ok        bool
+	}{
+		{"Available", 0, Critical, true},
+		{"Available", 1, Healthy, false},
+		{"Degraded", 1, Warning, true},
+		{"Degraded", 0, Healthy, false},
+		{"Progressing", 1, Healthy, true},
+		{"Progressing", 0, Healthy, false},
+		{"Upgradeable", 0, Healthy, false},
+	}
+	for _, tt := range tests {
+		health, ok := conditionHealth(tt.condition, tt.value)
+		assert.Equal(t, tt.ok, ok, "%s=%v", tt.condition, tt.value)
+		assert.Equal(t, tt.health, health, "%s=%v", tt.condition, tt.value)
+	}
+}
+
+// TestMapClusterOperatorConditions tests the conditions are mapped to the
+// core components of the cluster operators.
+func TestMapClusterOperatorConditions(t *testing.T) {
+	healthMaps := MapClusterOperatorConditions([]prom.Metric{
+		{Labels: map[string]string{"name": "etcd", "condition": "Degraded", "reason": "NodeController"}, Value: 1},
+		{Labels: map[string]string{"name": "etcd", "condition": "Available"}, Value: 1},
+		{Labels: map[string]string{"name": "ingress", "condition": "Available"}, Value: 0},
+	})
+
+	assert.Equal(t, []ComponentHealthMap{
+		{Layer: "core", Component: "etcd", SrcType: ClusterOperatorCondition, Health: Warning,
+			SrcLabels: map[string]string{"name": "etcd", "condition": "Degraded"}},
+		{Layer: "core", Component: "ingress", SrcType: ClusterOperatorCondition, Health: Critical,
+			SrcLabels: map[string]string{"name": "ingress", "condition": "Available"}},
+	}, healthMaps)
+}
+
+// TestConditionsIgnoredOnRestart tests the exported conditions don't remap
+// the groups of the alerts with the same labels on restart.
+func TestConditionsIgnoredOnRestart(t *testing.T) {
+	start := model.TimeFromUnixNano(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	var samples []model.SamplePair
+	for m := range 10 {
+		samples = append(samples, model.SamplePair{Timestamp: start.Add(time.Duration(m) * time.Minute), Value: 1})
+	}
+
+	healthMap := MapClusterOperatorConditions([]prom.Metric{
+		{Labels: map[string]string{"name": "etcd", "condition": "Degraded"}, Value: 1},
+	})[0]
+	healthMapRV := prom.RangeVector{{Metric: prom.LabelSet{Labels: healthMap.Labels()}, Samples: samples, Step: time.Minute}}
+
+	gc := &GroupsCollection{}
+	gc.processHistoricalAlerts(prom.RangeVector{{
+		Metric: prom.Alert{Name: "ClusterOperatorDegraded", Labels: map[string]string{
+			"alertname": "ClusterOperatorDegraded", "name": "etcd", "condition": "Degraded", "severity": "warning"}},
+		Samples: samples,
+		Step:    time.Minute,
+	}}, 0)
+	gc.UpdateGroupUUIDs(healthMapRV)
+
+	require.NotEmpty(t, gc.Groups)
+	for _, g := range gc.Groups {
+		assert.NotEmpty(t, g.RootGroupID)
+	}
+}
@@ -0,0 +1,180 @@
+package processor
+
+// This file contains logic for detecting and publishing the incidents changes.
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// IncidentEventType is the type of the change of an incident.
+type IncidentEventType string
+
+const (
+	IncidentOpened   IncidentEventType = "opened"
+	IncidentUpdated  IncidentEventType = "updated"
+	IncidentResolved IncidentEventType = "resolved"
+)
+
+// IncidentEvent describes a change of an incident detected in a processing
+// iteration.
+type IncidentEvent struct {
+	Type    IncidentEventType `json:"type"`
+	Time    time.Time         `json:"time"`
+	GroupID string            `json:"group_id"`
+	// Severity is the highest severity of the incident alerts.
+	Severity string `json:"severity"`
+	// Components are the affected components in the layer/component form.
+	Components []string `json:"components"`
+	Alerts     []string `json:"alerts"`
+}
+
+// incidentState is the state of an incident, compared between iterations
+// to detect the changes.
+type incidentState struct {
+	severity   HealthValue
+	components []string
+	alerts     []string
+}
+
+func (s incidentState) equal(other incidentState) bool {
+	return s.severity == other.severity &&
+		slices.Equal(s.components, other.components) &&
+		slices.Equal(s.alerts, other.alerts)
+}
+
+// incidentStates summarizes the health maps into states of the incidents.
+//
+// Health maps without a group ID are not part of any incident.
+func incidentStates(healthMaps []ComponentHealthMap) map[string]incidentState {
+	states := make(map[string]incidentState)
+	for _, hm := range healthMaps {
+		if hm.GroupId == "" {
+			continue
+		}
+		state := states[hm.GroupId]
+		state.severity = max(state.severity, hm.Health)
+		if component := hm.Layer + "/" + hm.Component; !slices.Contains(state.components, component) {
+			state.components = append(state.components, component)
+		}
+		if alert := hm.SrcLabels["alertname"]; alert != "" && !slices.Contains(state.alerts, alert) {
+			state.alerts = append(state.alerts, alert)
+		}
+		states[hm.GroupId] = state
+	}
+	for _, state := range states {
+		slices.Sort(state.components)
+		slices.Sort(state.alerts)
+	}
+	return states
+}
+
+// diffIncidents returns the events turning the previous incidents
+// into the current ones, ordered by the group ID.
+func diffIncidents(prev, curr map[string]incidentState, t time.Time) []IncidentEvent {
+	var events []IncidentEvent
+	event := func(eventType IncidentEventType, groupID string, state incidentState) IncidentEvent {
+		return IncidentEvent{
+			Type:       eventType,
+			Time:       t,
+			GroupID:    groupID,
+			Severity:   state.severity.String(),
+			Components: state.components,
+			Alerts:     state.alerts,
+		}
+	}
+
+	for _, groupID := range sortedKeys(curr) {
+		state := curr[groupID]
+		prevState, ok := prev[groupID]
+		switch {
+		case !ok:
+			events = append(events, event(IncidentOpened, groupID, state))
+		case !state.equal(prevState):
+			events = append(events, event(IncidentUpdated, groupID, state))
+		}
+	}
+	for _, groupID := range sortedKeys(prev) {
+		if _, ok := curr[groupID]; !ok {
+			events = append(events, event(IncidentResolved, groupID, prev[groupID]))
+		}
+	}
+	return events
+}
+
+func sortedKeys(m map[string]incidentState) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// IncidentEvents distributes the incident events to the subscribers.
+//
+// Each subscriber has a bounded buffer. A subscriber not keeping up with
+// the events is dropped, by closing its channel, rather than blocking
+// the processing.
+type IncidentEvents struct {
+	bufferSize int
+
+	mu          sync.Mutex
+	subscribers map[chan IncidentEvent]struct{}
+}
+
+// NewIncidentEvents creates the events distribution with the given buffer
+// size per subscriber.
+func NewIncidentEvents(bufferSize int) *IncidentEvents {
+	return &IncidentEvents{
+		bufferSize:  bufferSize,
+		subscribers: make(map[chan IncidentEvent]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber.
+//
+// The returned channel is closed when the subscriber is dropped or canceled.
+// The cancel function must be called once the subscriber is done.
+func (e *IncidentEvents) Subscribe() (events <-chan IncidentEvent, cancel func()) {
+	ch := make(chan IncidentEvent, e.bufferSize)
+
+	e.mu.Lock()
+	e.subscribers[ch] = struct{}{}
+	e.mu.Unlock()
+
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.unsubscribe(ch)
+	}
+}
+
+// unsubscribe removes the subscriber. It expects the lock to be held.
+func (e *IncidentEvents) unsubscribe(ch chan IncidentEvent) {
+	if _, ok := e.subscribers[ch]; ok {
+		delete(e.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Publish sends the events to all the subscribers.
+func (e *IncidentEvents) Publish(events []IncidentEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subscribers {
+		for _, event := range events {
+			select {
+			case ch <- event:
+				continue
+			default:
+			}
+			slog.Warn("Dropping slow incident events subscriber")
+			e.unsubscribe(ch)
+			break
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package processor
 
 import (
+	"cmp"
 	"fmt"
+	"hash/fnv"
 	"log/slog"
 	"math"
+	"os"
 	"slices"
 	"sort"
 	"strings"
@@ -11,6 +14,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/prometheus/common/model"
+	"sigs.k8s.io/yaml"
 
 	"github.com/openshift/cluster-health-analyzer/pkg/prom"
 )
@@ -50,28 +54,67 @@ func (c Change) String() string {
 
 type ChangeSet []Change
 
-var noMatchAlerts = []labelsSubsetMatcher{
-	{Labels: map[string]string{"alertname": "Watchdog", "namespace": "openshift-monitoring"}},
-	{Labels: map[string]string{"alertname": "AlertmanagerReceiversNotConfigured", "namespace": "openshift-monitoring"}},
+// defaultNoMatchAlerts are the alerts excluded from the fuzzy matching
+// unless configured otherwise, see [GroupingConfig.NoMatchAlerts].
+var defaultNoMatchAlerts = []map[string]string{
+	{"alertname": "Watchdog", "namespace": "openshift-monitoring"},
+	{"alertname": "AlertmanagerReceiversNotConfigured", "namespace": "openshift-monitoring"},
 }
 
-func MetricsIntervals(rangeVector prom.RangeVector) []Interval {
+// LoadNoMatchAlerts reads the label sets of the alerts excluded from
+// the fuzzy matching from a YAML or JSON file.
+func LoadNoMatchAlerts(path string) ([]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var alerts []map[string]string
+	if err := yaml.UnmarshalStrict(data, &alerts); err != nil {
+		return nil, fmt.Errorf("invalid no-match alerts %s: %w", path, err)
+	}
+	for i, labels := range alerts {
+		if len(labels) == 0 {
+			return nil, fmt.Errorf("invalid no-match alerts %s: entry %d has no labels", path, i)
+		}
+	}
+	return alerts, nil
+}
+
+// MetricsIntervals converts the samples of the range vector into continuous
+// intervals.
+//
+// A gap between samples longer than the step ends the interval. The grace
+// extends the tolerated gap, so that a brief disappearance of the metric
+// (e.g. a single missed scrape) doesn't split the interval.
+func MetricsIntervals(rangeVector prom.RangeVector, grace time.Duration) []Interval {
+	return metricsIntervals(rangeVector, fixedGrace(grace))
+}
+
+// graceFn returns the resolution grace for the labels of a metric.
+type graceFn func(labels map[string]string) time.Duration
+
+func fixedGrace(grace time.Duration) graceFn {
+	return func(map[string]string) time.Duration { return grace }
+}
+
+func metricsIntervals(rangeVector prom.RangeVector, grace graceFn) []Interval {
 	if len(rangeVector) == 0 {
 		return nil
 	}
 	step := rangeVector[0].Step
 
-	ret := make([]Interval, 0)
+	ret := make([]Interval, 0, len(rangeVector))
 	for _, r := range rangeVector {
 		if len(r.Samples) == 0 {
 			continue
 		}
+		maxGap := step + grace(r.Metric.MLabels())
 		start := r.Samples[0].Timestamp
 		end := start
 
 		for i := 1; i < len(r.Samples); i++ {
 			sample := r.Samples[i]
-			if sample.Timestamp.Sub(end) > step {
+			if sample.Timestamp.Sub(end) > maxGap {
 				// The end of the previous interval.
 				ret = append(ret, Interval{Metric: r.Metric, Start: start, End: end})
 				// Start of the new interval.
@@ -91,35 +134,44 @@ func MetricsIntervals(rangeVector prom.RangeVector) []Interval {
 // MetricsChanges returns a list of changes in the alerts.
 //
 // The changes are grouped by the timestamp of the change and sorted
-// by the timestamp.
-func MetricsChanges(rangeVector prom.RangeVector) ChangeSet {
-	intervals := MetricsIntervals(rangeVector)
+// by the timestamp. See [MetricsIntervals] for the meaning of grace.
+func MetricsChanges(rangeVector prom.RangeVector, grace time.Duration) ChangeSet {
+	return metricsChanges(rangeVector, fixedGrace(grace))
+}
+
+func metricsChanges(rangeVector prom.RangeVector, grace graceFn) ChangeSet {
+	intervals := metricsIntervals(rangeVector, grace)
 	if len(intervals) == 0 {
 		return nil
 	}
 
-	var ret ChangeSet
-
-	sort.Slice(intervals, func(i, j int) bool {
-		return intervals[i].Start.Before(intervals[j].Start)
+	// The stable sort keeps the order of the series for intervals with
+	// the same start.
+	slices.SortStableFunc(intervals, func(a, b Interval) int {
+		return cmp.Compare(a.Start, b.Start)
 	})
-	currGroup := make([]Interval, 0)
-	currTime := intervals[0].Start
 
-	for _, i := range intervals {
-		if currTime == i.Start {
-			// The same start - group together.
-			currGroup = append(currGroup, i)
-		} else {
-			// Different start: save the current group and start a new one.
-			ret = append(ret, Change{Timestamp: currTime, Intervals: currGroup})
+	changesCount := 1
+	for i := 1; i < len(intervals); i++ {
+		if intervals[i].Start != intervals[i-1].Start {
+			changesCount++
+		}
+	}
 
-			currGroup = []Interval{i}
-			currTime = i.Start
+	// The changes share the sorted intervals slice instead of copying them.
+	// The capacity is limited so that appending to a change doesn't overwrite
+	// the following one.
+	ret := make(ChangeSet, 0, changesCount)
+	groupStart := 0
+	for i := 1; i <= len(intervals); i++ {
+		if i == len(intervals) || intervals[i].Start != intervals[groupStart].Start {
+			ret = append(ret, Change{
+				Timestamp: intervals[groupStart].Start,
+				Intervals: intervals[groupStart:i:i],
+			})
+			groupStart = i
 		}
 	}
-	// Save the last group.
-	ret = append(ret, Change{Timestamp: currTime, Intervals: currGroup})
 	return ret
 }
 
@@ -195,6 +247,8 @@ func (g *GroupMatcher) expandMatchers(matchers []labelsSubsetMatcher) {
 type match struct {
 	GroupMatcher *GroupMatcher
 	TimeDist     time.Duration
+	// Score is the distance of the match adjusted by the label weights.
+	Score float64
 }
 
 func newGroupMatcherSubset(labels map[string]string, keys []string, distance float64) *GroupMatcher {
@@ -218,22 +272,51 @@ func watchdogAlert(i Interval) bool {
 		i.Metric.MLabels()["namespace"] == "openshift-monitoring"
 }
 
-func alertFuzzyLabels(i Interval) map[string]string {
-	for _, m := range noMatchAlerts {
+func (gc *GroupsCollection) alertFuzzyLabels(i Interval) map[string]string {
+	noMatchAlerts := gc.Config.NoMatchAlerts
+	if noMatchAlerts == nil {
+		noMatchAlerts = defaultNoMatchAlerts
+	}
+	for _, labels := range noMatchAlerts {
 		// For certain alerts, we don't want to do any fuzzy matching.
-		if match, _ := m.Matches(i.Metric.MLabels()); match {
+		if match, _ := (labelsSubsetMatcher{Labels: labels}).Matches(i.Metric.MLabels()); match {
 			return nil
 		}
 	}
-	// TODO: add option for some alerts to match some known pairs, but not others.
-	// E.g. APIRemovedInNextReleaseInUse and APIRemovedInNextEUSReleaseInUse
-	return getMapSubset(i.Metric.MLabels(), "alertname", "namespace")
+	labels := i.Metric.MLabels()
+	if len(gc.alertPairLabels(labels)) > 0 {
+		// The paired alerts are matched only with their known pairs.
+		return nil
+	}
+	keys := []string{"alertname", "namespace"}
+	if gc.Config.RuleGroupLabel != "" {
+		// Alerts from the same rule group were put together by the rule author,
+		// which is a good sign they are related.
+		keys = append(keys, gc.Config.RuleGroupLabel)
+	}
+	fuzzyLabels := getMapSubset(labels, keys...)
+
+	if gc.Config.GroupByComponent {
+		layer, component, _ := determineComponent(prom.Alert{Labels: labels}, gc.layers)
+		// Alerts not mapped to any component are still matched by namespace.
+		if layer != "Others" {
+			delete(fuzzyLabels, "namespace")
+			fuzzyLabels[componentFuzzyLabel] = layer + "/" + component
+		}
+	}
+
+	for _, fn := range gc.fuzzyLabelsFns {
+		for k, v := range fn(labels) {
+			fuzzyLabels[k] = v
+		}
+	}
+	return fuzzyLabels
 }
 
 // alertGroupMatchers returns a list of matchers for the alert.
 // This includes exact matcher with 0 distance, as well as various fuzzy matchers
 // based on the alert labels.
-func alertGroupMatchers(interval Interval) []*GroupMatcher {
+func (gc *GroupsCollection) alertGroupMatchers(interval Interval) []*GroupMatcher {
 	labels := interval.Metric.MLabels()
 	groups := []*GroupMatcher{
 		newGroupMatcherExact(labels),
@@ -241,7 +324,16 @@ func alertGroupMatchers(interval Interval) []*GroupMatcher {
 		newGroupMatcherSubset(labels, []string{"namespace", "alertname", "service", "job", "container"}, 1),
 	}
 
-	for k, v := range alertFuzzyLabels(interval) {
+	for k, v := range gc.alertPairLabels(labels) {
+		groups = append(groups, newGroupMatcherSubset(map[string]string{k: v}, []string{k}, pairMatchDistance))
+	}
+
+	if hint := gc.Config.HintLabel; hint != "" && labels[hint] != "" {
+		// The rule author asked for the alerts to be grouped together.
+		groups = append(groups, newGroupMatcherSubset(labels, []string{hint}, hintMatchDistance))
+	}
+
+	for k, v := range gc.alertFuzzyLabels(interval) {
 		groups = append(groups,
 			newGroupMatcherSubset(map[string]string{k: v}, []string{k}, 2),
 		)
@@ -254,12 +346,164 @@ func alertGroupMatchers(interval Interval) []*GroupMatcher {
 	return groups
 }
 
+// alertPairLabelPrefix prefixes the keys of the labels identifying the alert
+// pairs. They are not real labels, so they don't clash with the alert labels.
+const alertPairLabelPrefix = "__alert_pair__:"
+
+// pairMatchDistance is the distance of the matchers on the alert pairs.
+// It's preferred over the other fuzzy matching, but not over matching
+// the same alert.
+const pairMatchDistance = 1.5
+
+// alertPairLabels returns a label for each of the configured pairs
+// the alert is part of.
+func (gc *GroupsCollection) alertPairLabels(labels map[string]string) map[string]string {
+	alertname := labels["alertname"]
+	if alertname == "" {
+		return nil
+	}
+	var ret map[string]string
+	for _, pair := range gc.Config.AlertPairs {
+		if pair[0] != alertname && pair[1] != alertname {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string]string)
+		}
+		a, b := min(pair[0], pair[1]), max(pair[0], pair[1])
+		ret[alertPairLabelPrefix+a+"/"+b] = "true"
+	}
+	return ret
+}
+
+// hintMatchDistance is the distance of the matchers on the grouping hint
+// label. It's preferred over any other fuzzy matching.
+const hintMatchDistance = 0.5
+
+// GroupingConfig holds the optional settings of the alerts grouping.
+//
+// The zero value corresponds to the default behavior.
+type GroupingConfig struct {
+	// RuleGroupLabel is the name of the alert label carrying the PrometheusRule
+	// group the alert originates from (e.g. "rule_group"). When set, alerts
+	// from the same rule group are fuzzy-matched together.
+	RuleGroupLabel string
+
+	// HintLabel is the name of the alert label carrying a grouping hint set
+	// by the rule author (e.g. "incident_group"). Alerts sharing a value of
+	// the hint are grouped together regardless of their other labels.
+	HintLabel string
+
+	// TimeMatchLabels restricts the pure time-based grouping to alerts sharing
+	// a value of at least one of these labels (e.g. "namespace") with an alert
+	// already in the group. When empty, any alerts close enough in time are
+	// grouped together.
+	TimeMatchLabels []string
+
+	// MaxGroups limits the number of groups kept in the collection. When
+	// exceeded, the least recently modified fuzzy groups are evicted first.
+	// Direct matchers are never evicted. Zero means no limit.
+	MaxGroups int
+
+	// LabelWeights assigns weights to the labels used for fuzzy matching.
+	// When choosing between the fuzzy matches, the highest weight among the
+	// labels a match was made on reduces its distance, so that matches on
+	// stronger signals (e.g. namespace) win over weaker ones (e.g. alertname).
+	// The weights don't affect the other kinds of matches, which are always
+	// preferred over the fuzzy ones. Labels without a weight default to 1.
+	LabelWeights map[string]float64
+
+	// ResolutionGrace is the time an alert can be missing from the history
+	// before it's considered resolved. This prevents splitting an alert into
+	// multiple intervals (and possibly incidents) due to missed scrapes.
+	ResolutionGrace time.Duration
+
+	// SeverityResolutionGrace overrides the ResolutionGrace for the alerts
+	// of the given severities, e.g. to keep the critical incidents open
+	// longer while letting the info ones resolve quickly.
+	SeverityResolutionGrace map[HealthValue]time.Duration
+
+	// GroupByComponent fuzzy-matches alerts by the component they map to
+	// instead of their namespace. This allows grouping alerts of a component
+	// spanning multiple namespaces (e.g. an operand and its operator).
+	GroupByComponent bool
+
+	// NoMatchAlerts are the label sets of the alerts excluded from the fuzzy
+	// matching, e.g. the always firing ones. An alert is excluded when it has
+	// all the labels of any of the sets. When nil, the Watchdog and
+	// AlertmanagerReceiversNotConfigured alerts are excluded.
+	NoMatchAlerts []map[string]string
+
+	// AlertPairs are the pairs of alert names matched with each other,
+	// e.g. APIRemovedInNextReleaseInUse and APIRemovedInNextEUSReleaseInUse.
+	// The paired alerts are not fuzzy-matched with any other alerts.
+	AlertPairs [][2]string
+
+	// FuzzyMatchWindow is the time within which the alerts are fuzzy-matched
+	// to the existing groups. Defaults to DefaultFuzzyMatchWindow.
+	FuzzyMatchWindow time.Duration
+
+	// TimeMatchWindow is the time within which the alerts without any other
+	// match are grouped purely by time. Defaults to DefaultTimeMatchWindow.
+	TimeMatchWindow time.Duration
+
+	// DirectMatchWindow is the time within which the alerts are matched
+	// to the groups of the same alerts. Defaults to DefaultDirectMatchWindow.
+	DirectMatchWindow time.Duration
+}
+
+// Hash returns a short hash identifying the configuration.
+//
+// It allows the consumers to detect the incidents were grouped with
+// a different configuration.
+func (c GroupingConfig) Hash() string {
+	h := fnv.New32a()
+	// The fmt package prints the maps sorted by keys, so the output is stable.
+	fmt.Fprintf(h, "%+v", c)
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// componentFuzzyLabel is the key used in fuzzy labels for the component
+// the alert maps to. It's not a real label, so it doesn't clash with
+// the alert labels.
+const componentFuzzyLabel = "__component__"
+
+// FuzzyLabelsFn computes additional labels to fuzzy-match the alert on.
+//
+// Each of the returned labels is matched separately: alerts sharing a value
+// of any of them are grouped together.
+type FuzzyLabelsFn func(labels map[string]string) map[string]string
+
 type GroupsCollection struct {
+	Config GroupingConfig
 	Groups []*GroupMatcher
+
+	fuzzyLabelsFns []FuzzyLabelsFn
+	// layers are the additional layers the alerts are mapped to when
+	// grouping by component.
+	layers Layers
+	// timeMatchIndex caches the timeMatchLabels of the groups. It's kept up
+	// to date as the groups are added and reset to nil when they are removed
+	// or remapped, to be rebuilt on the next use.
+	timeMatchIndex map[string][]map[string]string
+	// auditLog records the grouping decisions, if set.
+	auditLog *slog.Logger
+}
+
+// RegisterFuzzyLabels registers a function extending the labels the alerts
+// are fuzzy-matched on, in addition to the built-in ones.
+//
+// This allows grouping by deployment specific labels (e.g. tenant).
+// The alerts excluded from fuzzy matching are not passed to the function.
+func (gc *GroupsCollection) RegisterFuzzyLabels(fn FuzzyLabelsFn) {
+	gc.fuzzyLabelsFns = append(gc.fuzzyLabelsFns, fn)
 }
 
 func (gc *GroupsCollection) AddGroup(g *GroupMatcher) {
 	gc.Groups = append(gc.Groups, g)
+	if gc.timeMatchIndex != nil {
+		gc.indexTimeMatchLabels(g)
+	}
 }
 
 func (gc *GroupsCollection) ProcessIntervalsBatch(intervals []Interval) []GroupedInterval {
@@ -275,11 +519,30 @@ func (gc *GroupsCollection) ProcessIntervalsBatch(intervals []Interval) []Groupe
 	return groupedIntervals
 }
 
-func (gc *GroupsCollection) processHistoricalAlerts(alertsRange prom.RangeVector) {
-	changes := MetricsChanges(alertsRange)
+// processHistoricalAlerts warms up the collection with the alerts history.
+//
+// Only the alerts active since the cutoff create the groups, as the older
+// ones contribute little to the current grouping. A zero cutoff processes
+// the whole history.
+func (gc *GroupsCollection) processHistoricalAlerts(alertsRange prom.RangeVector, cutoff model.Time) {
+	// The history is replayed on every start, auditing it would record
+	// the decisions made before the restart again.
+	auditLog := gc.auditLog
+	gc.auditLog = nil
+	defer func() { gc.auditLog = auditLog }()
+
+	changes := metricsChanges(alertsRange, gc.Config.resolutionGrace)
 
 	for _, change := range changes {
-		gc.ProcessIntervalsBatch(change.Intervals)
+		intervals := change.Intervals
+		if cutoff > 0 {
+			intervals = slices.DeleteFunc(slices.Clone(intervals), func(i Interval) bool {
+				return i.End.Before(cutoff)
+			})
+		}
+		if len(intervals) > 0 {
+			gc.ProcessIntervalsBatch(intervals)
+		}
 	}
 }
 
@@ -297,11 +560,16 @@ func (gc *GroupsCollection) ProcessAlertsBatch(alerts []prom.Alert, timestamp ti
 
 	groupedIntervals := gc.ProcessIntervalsBatch(intervals)
 
+	// Every interval is either matched to an existing group or gets a new
+	// root group in addIntervalsGroups, so the group matcher is expected to
+	// be always set. The guard keeps a regression from panicking.
 	ret := make([]prom.Alert, 0, len(alerts))
 	for _, gi := range groupedIntervals {
 		labels := gi.Metric.MLabels()
 		if gi.GroupMatcher != nil {
 			labels["group_id"] = gi.GroupMatcher.RootGroupID
+		} else {
+			slog.Warn("Alert not assigned to any group", "alertname", labels["alertname"])
 		}
 		ret = append(ret, prom.Alert{Name: labels["alertname"], Labels: labels})
 	}
@@ -311,11 +579,57 @@ func (gc *GroupsCollection) ProcessAlertsBatch(alerts []prom.Alert, timestamp ti
 // PruneGroups removes groups that can't be matched anymore.
 //
 // It calculates the threshold based on the provided time and removes groups.
-func (gc *GroupsCollection) PruneGroups(t time.Time) {
+// If the collection still exceeds the configured [GroupingConfig.MaxGroups],
+// the oldest fuzzy groups are evicted. It returns the number of evicted groups.
+func (gc *GroupsCollection) PruneGroups(t time.Time) (evicted int) {
 	// Directs matches have longer retention times.
-	gc.pruneGroupsBefore(0, 0, t.Add(-1*directMatchLongTimeDelta))
+	gc.pruneGroupsBefore(0, 0, t.Add(-1*gc.Config.directMatchWindow()))
 	// Fuzzy matches have shorter retention times.
-	gc.pruneGroupsBefore(1, math.Inf(1), t.Add(-1*fuzzyMatchTimeDelta))
+	gc.pruneGroupsBefore(hintMatchDistance, math.Inf(1), t.Add(-1*gc.Config.fuzzyMatchWindow()))
+
+	return gc.evictGroups()
+}
+
+// evictGroups removes the least recently modified fuzzy groups until the
+// collection fits into the configured limit.
+//
+// Direct matchers are kept, as they are still within their retention after
+// pruning and losing them would split ongoing incidents.
+func (gc *GroupsCollection) evictGroups() int {
+	maxGroups := gc.Config.MaxGroups
+	if maxGroups <= 0 || len(gc.Groups) <= maxGroups {
+		return 0
+	}
+
+	fuzzy := make([]*GroupMatcher, 0, len(gc.Groups))
+	for _, g := range gc.Groups {
+		if g.Distance > 0 {
+			fuzzy = append(fuzzy, g)
+		}
+	}
+	sort.SliceStable(fuzzy, func(i, j int) bool {
+		return fuzzy[i].Modified.Before(fuzzy[j].Modified)
+	})
+
+	toEvict := min(len(gc.Groups)-maxGroups, len(fuzzy))
+	evicted := make(map[*GroupMatcher]struct{}, toEvict)
+	for _, g := range fuzzy[:toEvict] {
+		evicted[g] = struct{}{}
+	}
+
+	newGroups := make([]*GroupMatcher, 0, len(gc.Groups)-toEvict)
+	for _, g := range gc.Groups {
+		if _, ok := evicted[g]; !ok {
+			newGroups = append(newGroups, g)
+		}
+	}
+	gc.Groups = newGroups
+	gc.timeMatchIndex = nil
+
+	if len(gc.Groups) > maxGroups {
+		slog.Warn("Groups limit exceeded by direct matchers", "groups", len(gc.Groups), "limit", maxGroups)
+	}
+	return toEvict
 }
 
 func (gc *GroupsCollection) pruneGroupsBefore(minDistance, maxDistance float64, t time.Time) {
@@ -330,6 +644,7 @@ func (gc *GroupsCollection) pruneGroupsBefore(minDistance, maxDistance float64,
 		newGroups = append(newGroups, g)
 	}
 	gc.Groups = newGroups
+	gc.timeMatchIndex = nil
 }
 
 func (gc *GroupsCollection) tryMatchIntervals(intervals []Interval) ([]GroupedInterval, []Interval) {
@@ -372,6 +687,7 @@ func (gc *GroupsCollection) newRootGroup(i Interval, inactive bool) *GroupMatche
 	}
 
 	gc.AddGroup(&ret)
+	gc.auditGroupCreated(&ret)
 	return &ret
 }
 
@@ -379,7 +695,7 @@ func (gc *GroupsCollection) addIntervalsGroups(intervals []Interval, groupMatche
 	if len(intervals) == 0 {
 		return nil
 	}
-	newGc := &GroupsCollection{}
+	newGc := &GroupsCollection{Config: gc.Config, fuzzyLabelsFns: gc.fuzzyLabelsFns, layers: gc.layers, auditLog: gc.auditLog}
 
 	isWatchdogGroup := false
 	for _, i := range intervals {
@@ -390,11 +706,12 @@ func (gc *GroupsCollection) addIntervalsGroups(intervals []Interval, groupMatche
 	}
 
 	ret := make([]GroupedInterval, 0, len(intervals))
-	if groupMatcher == nil && !isWatchdogGroup {
+	if groupMatcher == nil && !isWatchdogGroup && len(gc.Config.TimeMatchLabels) == 0 {
 		// If not provided, create a new root group for all intervals in this batch.
 		// We don't do this if watchdog is present in the group, as it indicates
 		// the alerts are together by accident (perhaps due to a restart or data
-		// outage).
+		// outage). With restricted time matching, the intervals are matched
+		// one by one instead, so that only the related ones are put together.
 		groupMatcher = newGc.newRootGroup(intervals[0], isWatchdogGroup)
 	}
 
@@ -409,12 +726,14 @@ func (gc *GroupsCollection) addIntervalsGroups(intervals []Interval, groupMatche
 		if iGroupMatcher == nil {
 			iGroupMatcher = newGc.newRootGroup(i, isWatchdogGroup)
 		}
+		// Taken before the group gets the matchers of the interval.
+		matchedLabels := gc.auditMatchedLabels(i, iGroupMatcher)
 
 		// If we didn't have a direct match, add additional fuzzy matchers
 		// for this interval. If Distance is 0, we assume the fuzzy matchers
 		// to be already present.
 		if iGroupMatcher.Distance > 0 {
-			newGroupCands := alertGroupMatchers(i)
+			newGroupCands := gc.alertGroupMatchers(i)
 			for _, g := range newGroupCands {
 				if g.Distance == iGroupMatcher.Distance && iGroupMatcher.isSubsetOf(g) {
 					iGroupMatcher.expandMatchers(g.Matchers)
@@ -432,7 +751,9 @@ func (gc *GroupsCollection) addIntervalsGroups(intervals []Interval, groupMatche
 			}
 		}
 
-		ret = append(ret, GroupedInterval{i, iGroupMatcher})
+		gi := GroupedInterval{i, iGroupMatcher}
+		gc.auditAlertAssigned(gi, matchedLabels)
+		ret = append(ret, gi)
 	}
 	for _, g := range newGc.Groups {
 		gc.AddGroup(g)
@@ -440,47 +761,72 @@ func (gc *GroupsCollection) addIntervalsGroups(intervals []Interval, groupMatche
 	return ret
 }
 
-var (
+// Default matching windows, see the corresponding fields of [GroupingConfig].
+const (
 	// Unless we have a direct match, we try fuzzy matching.
-	fuzzyMatchTimeDelta = 24 * time.Hour
+	DefaultFuzzyMatchWindow = 24 * time.Hour
 
 	// If we have no match yet, we try to match on the time, but just very close events.
-	timeMatchTimeDelta = 15 * time.Minute
+	DefaultTimeMatchWindow = 15 * time.Minute
 
 	// No match yet: look for direct matches deeper in the past.
-	directMatchLongTimeDelta = 5 * 24 * time.Hour
+	DefaultDirectMatchWindow = 5 * 24 * time.Hour
 )
 
+// resolutionGrace returns the resolution grace for the severity of the alert.
+func (c GroupingConfig) resolutionGrace(labels map[string]string) time.Duration {
+	if severity, ok := ParseSeverity(labels["severity"]); ok {
+		if grace, ok := c.SeverityResolutionGrace[severity]; ok {
+			return grace
+		}
+	}
+	return c.ResolutionGrace
+}
+
+func (c GroupingConfig) fuzzyMatchWindow() time.Duration {
+	return cmp.Or(c.FuzzyMatchWindow, DefaultFuzzyMatchWindow)
+}
+
+func (c GroupingConfig) timeMatchWindow() time.Duration {
+	return cmp.Or(c.TimeMatchWindow, DefaultTimeMatchWindow)
+}
+
+func (c GroupingConfig) directMatchWindow() time.Duration {
+	return cmp.Or(c.DirectMatchWindow, DefaultDirectMatchWindow)
+}
+
 func (gc *GroupsCollection) bestMatch(interval Interval) *GroupMatcher {
 	matches := gc.matches(interval)
 	var directLongMatch *match
 	var shortCandidates []match
 	var shortMatch *match
+	fuzzyMatchWindow := gc.Config.fuzzyMatchWindow()
+	directMatchWindow := gc.Config.directMatchWindow()
 	sort.Slice(matches, func(i, j int) bool {
 		return matches[i].TimeDist < matches[j].TimeDist
 	})
 
 	for _, m := range matches {
-		if m.TimeDist <= fuzzyMatchTimeDelta {
+		if m.TimeDist <= fuzzyMatchWindow {
 			shortCandidates = append(shortCandidates, m)
 			continue
 		}
 
-		if m.TimeDist <= directMatchLongTimeDelta && m.GroupMatcher.Distance == 0 {
+		if m.TimeDist <= directMatchWindow && m.GroupMatcher.Distance == 0 {
 			directLongMatch = &m
-			// Given matches are sorted by time and we crossed the fuzzyMatchTimeDelta,
+			// Given matches are sorted by time and we crossed the fuzzyMatchWindow,
 			// there is no change to match anything better at this point.
 			break
 		}
 	}
 
 	if len(shortCandidates) > 0 {
-		// Try to find the match with the smallest distance.
+		// Try to find the match with the smallest (weighted) distance.
 		// In case of the same distance, it's fine for the first match to win,
 		// as we sort the matches by time initially.
 		shortMatch = &shortCandidates[0]
 		for i := 1; i < len(shortCandidates); i++ {
-			if shortCandidates[i].GroupMatcher.Distance < shortMatch.GroupMatcher.Distance {
+			if shortCandidates[i].Score < shortMatch.Score {
 				shortMatch = &shortCandidates[i]
 			}
 		}
@@ -499,7 +845,13 @@ func (gc *GroupsCollection) bestMatch(interval Interval) *GroupMatcher {
 func (gc *GroupsCollection) matches(interval Interval) []match {
 	var ret []match
 	allLabels := interval.Metric.MLabels()
-	fuzzyLabels := alertFuzzyLabels(interval)
+	fuzzyLabels := gc.alertFuzzyLabels(interval)
+	pairLabels := gc.alertPairLabels(allLabels)
+	var timeMatchLabels map[string][]map[string]string
+	if len(gc.Config.TimeMatchLabels) > 0 {
+		timeMatchLabels = gc.timeMatchLabels()
+	}
+	timeMatchWindow := gc.Config.timeMatchWindow()
 	for _, g := range gc.Groups {
 		var timeDist time.Duration
 		if g.Distance == 0 {
@@ -516,20 +868,25 @@ func (gc *GroupsCollection) matches(interval Interval) []match {
 		}
 
 		// Pure time-based grouping
-		if g.Distance == math.Inf(1) && timeDist <= timeMatchTimeDelta {
-			ret = append(ret, match{g, timeDist})
+		if g.Distance == math.Inf(1) && timeDist <= timeMatchWindow {
+			if timeMatchLabels == nil || sharesLabel(timeMatchLabels[g.RootGroupID], allLabels) {
+				ret = append(ret, match{g, timeDist, g.Distance})
+			}
 			continue
 		}
 
 		labels := allLabels
 		// For fuzzy matching, we use only a subset of labels that can be overriden
 		// on per-alert basis.
-		if g.Distance >= 2 {
+		switch {
+		case g.Distance == pairMatchDistance:
+			labels = pairLabels
+		case g.Distance >= 2:
 			labels = fuzzyLabels
 		}
 		for _, m := range g.Matchers {
-			if matched, _ := m.Matches(labels); matched {
-				ret = append(ret, match{g, timeDist})
+			if matched, keys := m.Matches(labels); matched {
+				ret = append(ret, match{g, timeDist, gc.matchScore(g, keys)})
 				// We found a match for this group: no need to check other matchers.
 				break
 			}
@@ -538,6 +895,80 @@ func (gc *GroupsCollection) matches(interval Interval) []match {
 	return ret
 }
 
+// matchScore returns the distance of the match adjusted by the weights of
+// the labels it matched on.
+//
+// The weights only order the fuzzy matches among themselves: their score is
+// kept above the pairMatchDistance, so that no weight makes a fuzzy match
+// win over the exact, hint, subset or pair matches.
+func (gc *GroupsCollection) matchScore(g *GroupMatcher, keys []string) float64 {
+	if g.Distance < 2 {
+		return g.Distance
+	}
+	return pairMatchDistance + (g.Distance-pairMatchDistance)/gc.labelsWeight(keys)
+}
+
+// labelsWeight returns the highest configured weight of the provided labels.
+func (gc *GroupsCollection) labelsWeight(keys []string) float64 {
+	weight := 0.0
+	for _, k := range keys {
+		w, ok := gc.Config.LabelWeights[k]
+		if !ok {
+			w = 1
+		}
+		weight = max(weight, w)
+	}
+	if weight <= 0 {
+		return 1
+	}
+	return weight
+}
+
+// timeMatchLabels returns the values of the configured time-match labels
+// of the alerts in the groups, indexed by the root group ID.
+//
+// The values are taken from the exact matchers, which carry all the labels
+// of the alerts in the group. The index is built once and then maintained
+// by AddGroup.
+func (gc *GroupsCollection) timeMatchLabels() map[string][]map[string]string {
+	if gc.timeMatchIndex == nil {
+		gc.timeMatchIndex = make(map[string][]map[string]string)
+		for _, g := range gc.Groups {
+			gc.indexTimeMatchLabels(g)
+		}
+	}
+	return gc.timeMatchIndex
+}
+
+// indexTimeMatchLabels adds the time-match labels of the group to the index.
+//
+// The matchers of the exact groups don't change once added, so they are
+// indexed only once.
+func (gc *GroupsCollection) indexTimeMatchLabels(g *GroupMatcher) {
+	if g.Distance != 0 {
+		return
+	}
+	for _, m := range g.Matchers {
+		labels := getMapSubset(m.Labels, gc.Config.TimeMatchLabels...)
+		if len(labels) > 0 {
+			gc.timeMatchIndex[g.RootGroupID] = append(gc.timeMatchIndex[g.RootGroupID], labels)
+		}
+	}
+}
+
+// sharesLabel returns true if any of the label sets has at least one
+// label with the same value as in the provided labels.
+func sharesLabel(labelSets []map[string]string, labels map[string]string) bool {
+	for _, ls := range labelSets {
+		for k, v := range ls {
+			if labels[k] == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 /// Previous Incidents Matcher
 ///
 /// The previous incidents matcher is used to match the current groups
@@ -597,22 +1028,30 @@ func (pim *previousIncidentsMatcher) match(labels map[string]string, time model.
 
 // srcLabels returns a map of labels that are not internal.
 // These labels are used for matching underlying metrics (e.g. alerts).
+//
+// The owner labels, exported by the older versions, are skipped as the
+// alerts in Prometheus don't have them.
 func srcLabels(labels map[string]string) map[string]string {
 	ret := make(map[string]string)
 	for k, v := range labels {
-		if strings.HasPrefix(k, SrcLabelPrefix) {
-			ret[k[len(SrcLabelPrefix):]] = v
+		if k, ok := strings.CutPrefix(k, SrcLabelPrefix); ok && k != ownerKindLabel && k != ownerNameLabel {
+			ret[k] = v
 		}
 	}
 	return ret
 }
 
 func newPreviousIncidentsMatcher(healthMapRV prom.RangeVector) *previousIncidentsMatcher {
-	componentsMapChanges := MetricsChanges(healthMapRV)
+	componentsMapChanges := MetricsChanges(healthMapRV, 0)
 	prevIncidents := make([]*previousIncident, 0, len(componentsMapChanges))
 	for _, change := range componentsMapChanges {
 		for _, interval := range change.Intervals {
 			labels := interval.Metric.MLabels()
+			// Only the grouped alerts identify the previous incidents, other
+			// sources (e.g. the cluster operator conditions) aren't grouped.
+			if labels["group_id"] == "" || (labels["type"] != "" && labels["type"] != string(Alert)) {
+				continue
+			}
 			prevIncidents = append(prevIncidents, &previousIncident{
 				matcher: &labelsSubsetMatcher{srcLabels(labels)},
 				uuid:    labels["group_id"],
@@ -634,6 +1073,8 @@ func newPreviousIncidentsMatcher(healthMapRV prom.RangeVector) *previousIncident
 }
 
 func (gc *GroupsCollection) UpdateGroupUUIDs(healthMapRV prom.RangeVector) {
+	// The index is keyed by the root group IDs being remapped.
+	gc.timeMatchIndex = nil
 	unmappedGroups := make(map[string][]*GroupMatcher)
 	mappedGroupIDs := make(map[string]struct{})
 
@@ -662,6 +1103,7 @@ func (gc *GroupsCollection) UpdateGroupUUIDs(healthMapRV prom.RangeVector) {
 			if prevIncident != nil {
 				newGroupID := prevIncident.uuid
 				oldGroupID := g.RootGroupID
+				gc.auditGroupRemapped(oldGroupID, newGroupID)
 				// Replace all occurrences of old group ID with the new one and.
 				for _, g := range unmappedGroups[oldGroupID] {
 					g.RootGroupID = newGroupID
@@ -1,13 +1,19 @@
 package processor
 
 import (
+	"bytes"
+	"encoding/json"
 	"math"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/openshift/cluster-health-analyzer/pkg/prom"
 	"github.com/openshift/cluster-health-analyzer/pkg/utils"
@@ -55,7 +61,7 @@ func TestGroupsCollectionProcessAlertsBatch(t *testing.T) {
 	assert.Equal(t, case3[0].Labels["group_id"], case3[1].Labels["group_id"])
 
 	// Case 4: Alert with same alertname as one from case 2 fires within
-	// [processor.fuzzyMatchTimeDelta] time range.
+	// [DefaultFuzzyMatchWindow] time range.
 	//
 	// It should match the group created in case 3.
 	alerts = []prom.Alert{
@@ -64,7 +70,7 @@ func TestGroupsCollectionProcessAlertsBatch(t *testing.T) {
 	case4 := gc.ProcessAlertsBatch(alerts, start.Add(7*time.Hour).Time())
 	assert.Equal(t, case3[0].Labels["group_id"], case4[0].Labels["group_id"])
 
-	// Case 5: Alert from the same namespace firing within [processor.fuzzyMatchTimeDelta]
+	// Case 5: Alert from the same namespace firing within [DefaultFuzzyMatchWindow]
 	//
 	// It should match with the last active group from the same namespace.
 	alerts = []prom.Alert{
@@ -107,7 +113,7 @@ func TestGroupsCollectionPruneGroups(t *testing.T) {
 
 	gc := GroupsCollection{}
 
-	// Time-based matcher should be pruned after [processor.fuzzyMatchTimeDelta]
+	// Time-based matcher should be pruned after [DefaultFuzzyMatchWindow]
 	gc.AddGroup(&GroupMatcher{
 		GroupID:  "time-matcher",
 		Start:    start.Add(1 * time.Hour),
@@ -115,7 +121,7 @@ func TestGroupsCollectionPruneGroups(t *testing.T) {
 		End:      start.Add(3 * time.Hour),
 		Distance: math.Inf(1)})
 
-	// Fuzzy matcher should be pruned after [processor.fuzzyMatchTimeDelta]
+	// Fuzzy matcher should be pruned after [DefaultFuzzyMatchWindow]
 	gc.AddGroup(&GroupMatcher{
 		GroupID:  "fuzzy-matcher-old",
 		Start:    start.Add(1 * time.Hour),
@@ -215,7 +221,7 @@ func TestGroupsCollectionProcessHistoricalAlerts(t *testing.T) {
 
 	gc := GroupsCollection{}
 
-	gc.processHistoricalAlerts(alerts)
+	gc.processHistoricalAlerts(alerts, 0)
 
 	// Group GroupMatchers by group_id
 	groupsMap := make(map[string][]*GroupMatcher)
@@ -287,7 +293,7 @@ func TestGroupsCollectionUpdateGroupUUIDs(t *testing.T) {
 	alerts := utils.RelativeIntervalsToRangeVectors(alertsIntervals, start, 1*time.Minute)
 
 	gc := GroupsCollection{}
-	gc.processHistoricalAlerts(alerts)
+	gc.processHistoricalAlerts(alerts, 0)
 
 	mappings := utils.RelativeIntervalsToRangeVectors(mappingIntervals, start, 1*time.Minute)
 
@@ -309,3 +315,558 @@ func TestGroupsCollectionUpdateGroupUUIDs(t *testing.T) {
 	assert.Equal(t, groupedAlerts["group1"], []string{"AlertmanagerReceiversNotConfigured"})
 	assert.Equal(t, groupedAlerts["group2"], []string{"TargetDown", "KubeNodeNotReady"})
 }
+
+// TestGroupsCollectionRuleGroupLabel tests grouping of alerts from the same
+// PrometheusRule group.
+func TestGroupsCollectionRuleGroupLabel(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	batches := [][]prom.Alert{
+		{{Name: "RuleAlert1", Labels: map[string]string{
+			"alertname": "RuleAlert1", "namespace": "ns1", "rule_group": "storage.rules"}}},
+		{{Name: "RuleAlert2", Labels: map[string]string{
+			"alertname": "RuleAlert2", "namespace": "ns2", "rule_group": "storage.rules"}}},
+	}
+
+	// Without the rule group label configured, the alerts are unrelated.
+	groups := batchesGroupIDs(&GroupsCollection{}, start, time.Hour, batches...)
+	assert.NotEqual(t, groups["RuleAlert1"], groups["RuleAlert2"])
+
+	// With the rule group label configured, the alerts group together.
+	groups = batchesGroupIDs(&GroupsCollection{
+		Config: GroupingConfig{RuleGroupLabel: "rule_group"}}, start, time.Hour, batches...)
+	assert.Equal(t, groups["RuleAlert1"], groups["RuleAlert2"])
+}
+
+// TestGroupsCollectionTimeMatchLabels tests the restricted time-based grouping,
+// where simultaneous alerts are grouped only when sharing some of the labels.
+func TestGroupsCollectionTimeMatchLabels(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	batches := [][]prom.Alert{
+		{
+			{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "ns1"}},
+			{Name: "Alert2", Labels: map[string]string{"alertname": "Alert2", "namespace": "ns2"}},
+		},
+		{{Name: "Alert3", Labels: map[string]string{"alertname": "Alert3", "namespace": "ns3"}}},
+	}
+
+	// By default, all alerts close in time are grouped together.
+	groups := batchesGroupIDs(&GroupsCollection{}, start, 5*time.Minute, batches...)
+	assert.Equal(t, groups["Alert1"], groups["Alert2"])
+	assert.Equal(t, groups["Alert1"], groups["Alert3"])
+
+	// With restricted time matching, unrelated alerts stay separate.
+	gc := &GroupsCollection{Config: GroupingConfig{TimeMatchLabels: []string{"namespace"}}}
+	groups = batchesGroupIDs(gc, start, 5*time.Minute, batches...)
+	assert.NotEqual(t, groups["Alert1"], groups["Alert2"])
+	assert.NotEqual(t, groups["Alert1"], groups["Alert3"])
+	assert.NotEqual(t, groups["Alert2"], groups["Alert3"])
+
+	// The cached labels are kept up to date as the groups are added.
+	cached := gc.timeMatchIndex
+	require.Len(t, cached, 3)
+	gc.timeMatchIndex = nil
+	assert.Equal(t, gc.timeMatchLabels(), cached)
+}
+
+// TestGroupsCollectionMaxGroups tests eviction of groups exceeding the limit.
+//
+// The oldest fuzzy groups should be evicted first, while direct matchers
+// are kept.
+func TestGroupsCollectionMaxGroups(t *testing.T) {
+	start := model.TimeFromUnixNano(
+		time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+
+	gc := GroupsCollection{Config: GroupingConfig{MaxGroups: 3}}
+
+	addGroup := func(id string, modified time.Duration, distance float64) {
+		gc.AddGroup(&GroupMatcher{
+			GroupID:  id,
+			Start:    start,
+			Modified: start.Add(modified),
+			End:      start.Add(modified),
+			Distance: distance})
+	}
+	addGroup("direct-old", 1*time.Hour, 0)
+	addGroup("fuzzy-oldest", 2*time.Hour, 2)
+	addGroup("time-old", 3*time.Hour, math.Inf(1))
+	addGroup("fuzzy-recent", 5*time.Hour, 1)
+	addGroup("direct-recent", 5*time.Hour, 0)
+
+	evicted := gc.PruneGroups(start.Add(6 * time.Hour).Time())
+
+	assert.Equal(t, 2, evicted)
+	ids := make([]string, 0, len(gc.Groups))
+	for _, g := range gc.Groups {
+		ids = append(ids, g.GroupID)
+	}
+	assert.ElementsMatch(t, []string{"direct-old", "fuzzy-recent", "direct-recent"}, ids)
+}
+
+// TestGroupsCollectionLabelWeights tests the weighted scoring of matches,
+// where a fuzzy match on a stronger label wins over a more recent weaker one,
+// but never over the closer kinds of matches.
+func TestGroupsCollectionLabelWeights(t *testing.T) {
+	start := model.TimeFromUnixNano(
+		time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+
+	// The groups are built from the matchers of the alerts, as when grouping.
+	addGroups := func(gc *GroupsCollection, groupID string, labels map[string]string, t model.Time) {
+		for _, g := range gc.alertGroupMatchers(Interval{Metric: prom.LabelSet{Labels: labels}, Start: t, End: t}) {
+			g.GroupID, g.RootGroupID = groupID, groupID
+			gc.AddGroup(g)
+		}
+	}
+	newCollection := func(cfg GroupingConfig) *GroupsCollection {
+		gc := &GroupsCollection{Config: cfg}
+		addGroups(gc, "namespace-group", map[string]string{"alertname": "Alert1", "namespace": "ns1"}, start.Add(1*time.Hour))
+		addGroups(gc, "alertname-group", map[string]string{"alertname": "Alert2", "namespace": "ns2"}, start.Add(2*time.Hour))
+		return gc
+	}
+	interval := Interval{
+		Metric: prom.LabelSet{Labels: map[string]string{"alertname": "Alert2", "namespace": "ns1"}},
+		Start:  start.Add(3 * time.Hour),
+		End:    start.Add(3 * time.Hour),
+	}
+
+	// With uniform weights, the most recent match wins.
+	gc := newCollection(GroupingConfig{})
+	assert.Equal(t, "alertname-group", gc.bestMatch(interval).RootGroupID)
+
+	// With namespace weighted higher, the namespace match wins.
+	gc = newCollection(GroupingConfig{LabelWeights: map[string]float64{"namespace": 2}})
+	assert.Equal(t, "namespace-group", gc.bestMatch(interval).RootGroupID)
+
+	// A subset match on the same alert wins regardless of the weights.
+	weights := map[string]float64{"namespace": 10}
+	gc = newCollection(GroupingConfig{LabelWeights: weights})
+	addGroups(gc, "subset-group", map[string]string{"alertname": "Alert2", "namespace": "ns1", "pod": "p1"}, start)
+	match := gc.bestMatch(interval)
+	assert.Equal(t, "subset-group", match.RootGroupID)
+	assert.Equal(t, 1.0, match.Distance)
+
+	// As does a hint match.
+	gc = newCollection(GroupingConfig{HintLabel: "incident_group", LabelWeights: weights})
+	addGroups(gc, "hint-group", map[string]string{"alertname": "Alert3", "namespace": "ns3", "incident_group": "g1"}, start)
+	interval.Metric = prom.LabelSet{Labels: map[string]string{"alertname": "Alert4", "namespace": "ns1", "incident_group": "g1"}}
+	assert.Equal(t, "hint-group", gc.bestMatch(interval).RootGroupID)
+}
+
+// TestMetricsIntervalsResolutionGrace tests that a brief gap in the samples
+// doesn't split the interval when within the resolution grace.
+func TestMetricsIntervalsResolutionGrace(t *testing.T) {
+	start := model.TimeFromUnixNano(
+		time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	step := 1 * time.Minute
+
+	// A single missed sample at minute 3.
+	var samples []model.SamplePair
+	for _, m := range []int{0, 1, 2, 4, 5} {
+		samples = append(samples, model.SamplePair{
+			Timestamp: start.Add(time.Duration(m) * time.Minute), Value: 1})
+	}
+	rv := prom.RangeVector{{
+		Metric:  prom.LabelSet{Labels: map[string]string{"alertname": "Alert1"}},
+		Samples: samples,
+		Step:    step,
+	}}
+
+	// Without grace, the gap splits the interval.
+	intervals := MetricsIntervals(rv, 0)
+	assert.Len(t, intervals, 2)
+
+	// With grace, the interval continues.
+	intervals = MetricsIntervals(rv, 2*time.Minute)
+	assert.Len(t, intervals, 1)
+	assert.Equal(t, start, intervals[0].Start)
+	assert.Equal(t, start.Add(5*time.Minute), intervals[0].End)
+}
+
+// TestMetricsIntervalsSeverityResolutionGrace tests the alerts with the same
+// gap resolve according to the grace of their severity.
+func TestMetricsIntervalsSeverityResolutionGrace(t *testing.T) {
+	start := model.TimeFromUnixNano(
+		time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+
+	// Both alerts are missing for 3 minutes before firing again.
+	var samples []model.SamplePair
+	for _, m := range []int{0, 1, 2, 6, 7} {
+		samples = append(samples, model.SamplePair{
+			Timestamp: start.Add(time.Duration(m) * time.Minute), Value: 1})
+	}
+	rv := prom.RangeVector{
+		{
+			Metric:  prom.LabelSet{Labels: map[string]string{"alertname": "Critical1", "severity": "critical"}},
+			Samples: samples,
+			Step:    time.Minute,
+		},
+		{
+			Metric:  prom.LabelSet{Labels: map[string]string{"alertname": "Info1", "severity": "info"}},
+			Samples: samples,
+			Step:    time.Minute,
+		},
+	}
+
+	cfg := GroupingConfig{
+		ResolutionGrace: time.Minute,
+		SeverityResolutionGrace: map[HealthValue]time.Duration{
+			Critical: 5 * time.Minute,
+			Healthy:  0,
+		},
+	}
+	intervals := metricsIntervals(rv, cfg.resolutionGrace)
+
+	// The critical alert stays unresolved during the gap.
+	var criticalIntervals, infoIntervals []Interval
+	for _, i := range intervals {
+		if i.Metric.MLabels()["severity"] == "critical" {
+			criticalIntervals = append(criticalIntervals, i)
+		} else {
+			infoIntervals = append(infoIntervals, i)
+		}
+	}
+	assert.Len(t, criticalIntervals, 1)
+	assert.Equal(t, start.Add(7*time.Minute), criticalIntervals[0].End)
+
+	// The info alert resolves at the start of the gap.
+	assert.Len(t, infoIntervals, 2)
+	assert.Equal(t, start.Add(2*time.Minute), infoIntervals[0].End)
+
+	// The alerts of the other severities use the default grace.
+	assert.Equal(t, time.Minute, cfg.resolutionGrace(map[string]string{"severity": "warning"}))
+}
+
+// TestGroupsCollectionGroupByComponent tests grouping alerts of the same
+// component across its namespaces.
+func TestGroupsCollectionGroupByComponent(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	batches := [][]prom.Alert{
+		{{Name: "etcdMembersDown", Labels: map[string]string{
+			"alertname": "etcdMembersDown", "namespace": "openshift-etcd"}}},
+		{{Name: "EtcdOperatorDegraded", Labels: map[string]string{
+			"alertname": "EtcdOperatorDegraded", "namespace": "openshift-etcd-operator"}}},
+	}
+
+	// By default, alerts from different namespaces are not grouped.
+	groups := batchesGroupIDs(&GroupsCollection{}, start, time.Hour, batches...)
+	assert.NotEqual(t, groups["etcdMembersDown"], groups["EtcdOperatorDegraded"])
+
+	// Grouping by component puts them together.
+	groups = batchesGroupIDs(&GroupsCollection{
+		Config: GroupingConfig{GroupByComponent: true}}, start, time.Hour, batches...)
+	assert.Equal(t, groups["etcdMembersDown"], groups["EtcdOperatorDegraded"])
+}
+
+// TestGroupsCollectionGroupByLayerComponent tests grouping alerts by the
+// components of the additional layers.
+func TestGroupsCollectionGroupByLayerComponent(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	layers := Layers{{
+		Name:  "platform-addons",
+		After: "core",
+		Components: []LayerComponent{
+			{Name: "addons", Namespaces: []string{"openshift-addons", "openshift-addons-operator"}},
+		},
+	}}
+
+	process := func(gc *GroupsCollection) []GroupedInterval {
+		var groupedIntervals []GroupedInterval
+		for i, a := range [][2]string{{"AddonDown", "openshift-addons"}, {"AddonOperatorDegraded", "openshift-addons-operator"}} {
+			alert := prom.Alert{Name: a[0], Labels: map[string]string{
+				"alertname": a[0], "namespace": a[1], "severity": "warning"}}
+			groupedIntervals = append(groupedIntervals, gc.ProcessIntervalsBatch([]Interval{{
+				Metric: alert,
+				Start:  model.TimeFromUnixNano(start.Add(time.Duration(i) * time.Hour).UnixNano()),
+				End:    model.TimeFromUnixNano(start.Add(time.Duration(i)*time.Hour + time.Minute).UnixNano()),
+			}})...)
+		}
+		return groupedIntervals
+	}
+
+	// Without the layers, the alerts are not mapped to any component.
+	cfg := GroupingConfig{GroupByComponent: true}
+	assert.Len(t, BuildIncidents(process(&GroupsCollection{Config: cfg})), 2)
+
+	incidents := layers.BuildIncidents(process(&GroupsCollection{Config: cfg, layers: layers}))
+	require.Len(t, incidents, 1)
+	assert.Equal(t, []IncidentComponent{{Layer: "platform-addons", Component: "addons"}}, incidents[0].Components)
+}
+
+// TestGroupingConfigHash tests the hash of the configuration is stable
+// and changes with the settings.
+func TestGroupingConfigHash(t *testing.T) {
+	cfg := GroupingConfig{
+		RuleGroupLabel: "rule_group",
+		LabelWeights:   map[string]float64{"namespace": 2, "job": 0.5, "service": 1},
+	}
+	hash := cfg.Hash()
+	assert.Len(t, hash, 8)
+
+	// The hash is stable for the same config.
+	same := GroupingConfig{
+		RuleGroupLabel: "rule_group",
+		LabelWeights:   map[string]float64{"service": 1, "job": 0.5, "namespace": 2},
+	}
+	assert.Equal(t, hash, same.Hash())
+
+	// The hash changes with the config.
+	changed := cfg
+	changed.ResolutionGrace = 2 * time.Minute
+	assert.NotEqual(t, hash, changed.Hash())
+
+	changed = cfg
+	changed.LabelWeights = map[string]float64{"namespace": 3, "job": 0.5, "service": 1}
+	assert.NotEqual(t, hash, changed.Hash())
+
+	assert.NotEqual(t, hash, GroupingConfig{}.Hash())
+}
+
+// TestGroupsCollectionRegisterFuzzyLabels tests grouping alerts on the labels
+// of a registered function, in addition to the built-in ones.
+func TestGroupsCollectionRegisterFuzzyLabels(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	batches := [][]prom.Alert{
+		{{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "ns1", "tenant": "acme"}}},
+		{{Name: "Alert2", Labels: map[string]string{"alertname": "Alert2", "namespace": "ns2", "tenant": "acme"}}},
+	}
+
+	// By default, alerts from different namespaces are not grouped.
+	groups := batchesGroupIDs(&GroupsCollection{}, start, time.Hour, batches...)
+	assert.NotEqual(t, groups["Alert1"], groups["Alert2"])
+
+	// Matching on the tenant label puts them together.
+	gc := &GroupsCollection{}
+	gc.RegisterFuzzyLabels(func(labels map[string]string) map[string]string {
+		return getMapSubset(labels, "tenant")
+	})
+	groups = batchesGroupIDs(gc, start, time.Hour, batches...)
+	assert.Equal(t, groups["Alert1"], groups["Alert2"])
+}
+
+// benchmarkAlertsRange generates a range vector of alerts over a day, with
+// the alerts repeatedly firing and resolving at various times.
+func benchmarkAlertsRange(series int) prom.RangeVector {
+	start := model.TimeFromUnixNano(
+		time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	step := time.Minute
+
+	intervals := make([]utils.RelativeInterval, 0, series*10)
+	for s := 0; s < series; s++ {
+		labels := map[string]string{
+			"alertname": "Alert" + strconv.Itoa(s%50),
+			"namespace": "ns" + strconv.Itoa(s),
+		}
+		// Intervals of various lengths, starting at shared timestamps.
+		for i := 0; i < 10; i++ {
+			relStart := float64(i*140 + s%20*5)
+			intervals = append(intervals, utils.RelativeInterval{
+				Labels: labels,
+				Start:  relStart,
+				End:    relStart + float64(30+s%60),
+			})
+		}
+	}
+
+	rv := utils.RelativeIntervalsToRangeVectors(intervals, start, step)
+	// Merge the intervals of the same series.
+	merged := make(prom.RangeVector, 0, series)
+	for i := 0; i < len(rv); i += 10 {
+		r := rv[i]
+		for _, next := range rv[i+1 : i+10] {
+			r.Samples = append(r.Samples, next.Samples...)
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func BenchmarkMetricsChanges(b *testing.B) {
+	rv := benchmarkAlertsRange(2000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MetricsChanges(rv, 0)
+	}
+}
+
+// TestGroupsCollectionAuditLog tests the grouping decisions are recorded
+// in the audit log.
+func TestGroupsCollectionAuditLog(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	gc := GroupsCollection{}
+	gc.SetAuditLog(&buf)
+
+	alerts := gc.ProcessAlertsBatch([]prom.Alert{
+		{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "ns1"}},
+		{Name: "Alert2", Labels: map[string]string{"alertname": "Alert2", "namespace": "ns1"}},
+	}, start)
+	gc.ProcessAlertsBatch([]prom.Alert{
+		{Name: "Alert3", Labels: map[string]string{"alertname": "Alert3", "namespace": "ns1"}},
+	}, start.Add(5*time.Minute))
+	groupID := alerts[0].Labels["group_id"]
+
+	var entries []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry map[string]any
+		assert.NoError(t, dec.Decode(&entry))
+		entries = append(entries, entry)
+	}
+
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry["msg"].(string))
+		assert.Equal(t, groupID, entry["group_id"])
+	}
+	assert.Equal(t, []string{
+		auditGroupCreated, auditAlertAssigned, auditAlertAssigned, auditAlertAssigned,
+	}, actions)
+	assert.Equal(t, "Alert1", entries[1]["alertname"])
+	assert.Equal(t, "inf", entries[1]["distance"])
+	assert.Nil(t, entries[1]["matched_labels"])
+	assert.Equal(t, "Alert3", entries[3]["alertname"])
+	// Alert3 is fuzzy-matched on the namespace.
+	assert.Equal(t, 2.0, entries[3]["distance"])
+	assert.Equal(t, map[string]any{"namespace": "ns1"}, entries[3]["matched_labels"])
+
+	// The warm-up from the alerts history is not recorded.
+	buf.Reset()
+	gc.processHistoricalAlerts(utils.RelativeIntervalsToRangeVectors([]utils.RelativeInterval{
+		{Labels: map[string]string{"alertname": "Alert4", "namespace": "ns2"}, Start: 0, End: 10},
+	}, model.TimeFromUnixNano(start.UnixNano()), time.Minute), 0)
+	assert.NotEmpty(t, gc.Groups)
+	assert.Zero(t, buf.Len())
+	require.NotNil(t, gc.auditLog)
+}
+
+// TestGroupsCollectionProcessHistoricalAlertsCutoff tests the alerts
+// resolved before the cutoff don't create groups on warm-up.
+func TestGroupsCollectionProcessHistoricalAlertsCutoff(t *testing.T) {
+	start := model.TimeFromUnixNano(
+		time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+
+	alerts := utils.RelativeIntervalsToRangeVectors([]utils.RelativeInterval{
+		{Labels: map[string]string{"alertname": "OldAlert", "namespace": "ns1"}, Start: 0, End: 100},
+		{Labels: map[string]string{"alertname": "RecentAlert", "namespace": "ns2"}, Start: 3000, End: 3010},
+	}, start, time.Minute)
+
+	gc := GroupsCollection{}
+	gc.processHistoricalAlerts(alerts, start.Add(2000*time.Minute))
+
+	var alertnames []string
+	for _, g := range gc.Groups {
+		for _, m := range g.Matchers {
+			if alert := m.Labels["alertname"]; alert != "" && !slices.Contains(alertnames, alert) {
+				alertnames = append(alertnames, alert)
+			}
+		}
+	}
+	assert.Equal(t, []string{"RecentAlert"}, alertnames)
+}
+
+// TestGroupsCollectionTimeMatchWindow tests a tightened time match window
+// prevents grouping of unrelated alerts.
+func TestGroupsCollectionTimeMatchWindow(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	batches := [][]prom.Alert{
+		{{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "ns1"}}},
+		{{Name: "Alert2", Labels: map[string]string{"alertname": "Alert2", "namespace": "ns2"}}},
+	}
+
+	// By default, alerts within 15 minutes are grouped together.
+	groups := batchesGroupIDs(&GroupsCollection{}, start, 5*time.Minute, batches...)
+	assert.Equal(t, groups["Alert1"], groups["Alert2"])
+
+	groups = batchesGroupIDs(&GroupsCollection{
+		Config: GroupingConfig{TimeMatchWindow: time.Minute}}, start, 5*time.Minute, batches...)
+	assert.NotEqual(t, groups["Alert1"], groups["Alert2"])
+}
+
+// TestGroupsCollectionHintLabel tests the alerts sharing a grouping hint
+// are grouped together regardless of their other labels.
+func TestGroupsCollectionHintLabel(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	batches := [][]prom.Alert{
+		{{Name: "NetworkLatencyHigh", Labels: map[string]string{
+			"alertname": "NetworkLatencyHigh", "namespace": "openshift-ovn-kubernetes",
+			"incident_group": "network-storm"}}},
+		{{Name: "IngressErrorsHigh", Labels: map[string]string{
+			"alertname": "IngressErrorsHigh", "namespace": "openshift-ingress",
+			"incident_group": "network-storm"}}},
+	}
+
+	groups := batchesGroupIDs(&GroupsCollection{}, start, time.Hour, batches...)
+	assert.NotEqual(t, groups["NetworkLatencyHigh"], groups["IngressErrorsHigh"])
+
+	groups = batchesGroupIDs(&GroupsCollection{
+		Config: GroupingConfig{HintLabel: "incident_group"}}, start, time.Hour, batches...)
+	assert.Equal(t, groups["NetworkLatencyHigh"], groups["IngressErrorsHigh"])
+}
+
+// TestGroupsCollectionNoMatchAlerts tests the configured alerts are excluded
+// from the fuzzy matching instead of the default ones.
+func TestGroupsCollectionNoMatchAlerts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "no-match-alerts.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- alertname: NoisyAlert
+`), 0o644))
+	noMatchAlerts, err := LoadNoMatchAlerts(path)
+	require.NoError(t, err)
+
+	interval := func(labels map[string]string) Interval {
+		return Interval{Metric: prom.Alert{Labels: labels}}
+	}
+	noisy := interval(map[string]string{"alertname": "NoisyAlert", "namespace": "ns1"})
+	watchdog := interval(map[string]string{"alertname": "Watchdog", "namespace": "openshift-monitoring"})
+
+	gc := GroupsCollection{}
+	assert.NotEmpty(t, gc.alertFuzzyLabels(noisy))
+	assert.Empty(t, gc.alertFuzzyLabels(watchdog))
+
+	gc = GroupsCollection{Config: GroupingConfig{NoMatchAlerts: noMatchAlerts}}
+	assert.Empty(t, gc.alertFuzzyLabels(noisy))
+	assert.NotEmpty(t, gc.alertFuzzyLabels(watchdog))
+}
+
+// TestGroupsCollectionProcessAlertsBatchGroupID tests every processed alert
+// gets a group ID, including the alerts excluded from fuzzy matching.
+func TestGroupsCollectionProcessAlertsBatchGroupID(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	batch := func() []prom.Alert {
+		return []prom.Alert{
+			{Name: "Watchdog", Labels: map[string]string{"alertname": "Watchdog", "namespace": "openshift-monitoring"}},
+			{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "ns1"}},
+			{Name: "Alert2", Labels: map[string]string{"alertname": "Alert2", "namespace": "ns2"}},
+			{Name: "Alert3", Labels: map[string]string{"alertname": "Alert3"}},
+		}
+	}
+
+	for _, cfg := range []GroupingConfig{{}, {TimeMatchLabels: []string{"namespace"}}} {
+		gc := GroupsCollection{Config: cfg}
+		for i := range 3 {
+			alerts := gc.ProcessAlertsBatch(batch(), start.Add(time.Duration(i)*time.Hour))
+			require.Len(t, alerts, 4)
+			for _, a := range alerts {
+				assert.NotEmpty(t, a.Labels["group_id"], "%+v: %s", cfg, a.Name)
+			}
+		}
+	}
+}
+
+// TestGroupsCollectionAlertPairs tests the paired alerts are grouped with
+// each other, but not with other alerts from the same namespace.
+func TestGroupsCollectionAlertPairs(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	gc := GroupsCollection{Config: GroupingConfig{
+		AlertPairs: [][2]string{{"APIRemovedInNextReleaseInUse", "APIRemovedInNextEUSReleaseInUse"}},
+	}}
+	var batches [][]prom.Alert
+	for _, alertname := range []string{"APIRemovedInNextReleaseInUse", "APIRemovedInNextEUSReleaseInUse", "KubeAPIErrorBudgetBurn"} {
+		batches = append(batches, []prom.Alert{{Name: alertname, Labels: map[string]string{
+			"alertname": alertname, "namespace": "openshift-kube-apiserver"}}})
+	}
+
+	groups := batchesGroupIDs(&gc, start, time.Hour, batches...)
+	assert.Equal(t, groups["APIRemovedInNextReleaseInUse"], groups["APIRemovedInNextEUSReleaseInUse"])
+	assert.NotEqual(t, groups["APIRemovedInNextReleaseInUse"], groups["KubeAPIErrorBudgetBurn"])
+}
@@ -0,0 +1,221 @@
+package processor
+
+// This file contains logic for summarizing the incidents per layer.
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// summaryLayers are the layers always present in the layer summary,
+// from the most foundational one.
+var summaryLayers = []string{"compute", "core", "workload"}
+
+// LayerSummary is the overview of the active incidents affecting a layer.
+type LayerSummary struct {
+	Layer string `json:"layer"`
+	// Incidents is the number of the active incidents affecting the layer.
+	Incidents int `json:"incidents"`
+	// Severity is the worst severity of the layer health maps, empty when
+	// the layer is not affected.
+	Severity string `json:"severity,omitempty"`
+}
+
+// SummarizeLayers aggregates the health maps per layer.
+//
+// The compute, core and workload layers are always included, followed
+// by any other layers sorted by name. Health maps without a group ID are
+// counted as separate incidents.
+func SummarizeLayers(healthMaps []ComponentHealthMap) []LayerSummary {
+	type layerState struct {
+		groups    map[string]struct{}
+		ungrouped int
+		severity  HealthValue
+	}
+	states := make(map[string]*layerState)
+	for _, hm := range healthMaps {
+		state, ok := states[hm.Layer]
+		if !ok {
+			state = &layerState{groups: make(map[string]struct{})}
+			states[hm.Layer] = state
+		}
+		if hm.GroupId == "" {
+			state.ungrouped++
+		} else {
+			state.groups[hm.GroupId] = struct{}{}
+		}
+		state.severity = max(state.severity, hm.Health)
+	}
+
+	layers := slices.Clone(summaryLayers)
+	var others []string
+	for layer := range states {
+		if !slices.Contains(summaryLayers, layer) {
+			others = append(others, layer)
+		}
+	}
+	slices.Sort(others)
+	layers = append(layers, others...)
+
+	summaries := make([]LayerSummary, 0, len(layers))
+	for _, layer := range layers {
+		summary := LayerSummary{Layer: layer}
+		if state, ok := states[layer]; ok {
+			summary.Incidents = len(state.groups) + state.ungrouped
+			summary.Severity = state.severity.String()
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// layerSummaries holds the layer summary from the last processing iteration.
+type layerSummaries struct {
+	mu        sync.RWMutex
+	summaries []LayerSummary
+}
+
+func (l *layerSummaries) set(summaries []LayerSummary) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.summaries = summaries
+}
+
+func (l *layerSummaries) get() []LayerSummary {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.summaries
+}
+
+// builtinLayers are the layers of the built-in component matchers, from
+// the most foundational one, together with the rank of their first component.
+var builtinLayers = []struct {
+	name     string
+	baseRank int
+}{
+	{"compute", 1},
+	{"core", 10},
+	{"workload", 1000},
+}
+
+// Layer defines an additional layer of components, on top of the built-in
+// compute, core and workload layers.
+type Layer struct {
+	// Name of the layer.
+	Name string `json:"name"`
+	// After is the name of the layer this one is ordered after, either
+	// a built-in layer or a previously defined one.
+	After string `json:"after"`
+	// Components of the layer, in the order of their ranking.
+	Components []LayerComponent `json:"components"`
+}
+
+// LayerComponent maps the alerts from the namespaces to a component.
+type LayerComponent struct {
+	Name       string   `json:"name"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// Layers are the additional layers of components.
+//
+// The components of the additional layers take precedence over the built-in
+// core and workload components, so that the deployments can reassign them.
+// The zero value maps the alerts to the built-in layers only.
+type Layers []Layer
+
+// LoadLayers reads the additional layers from a YAML or JSON file.
+func LoadLayers(path string) (Layers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var layers Layers
+	if err := yaml.UnmarshalStrict(data, &layers); err != nil {
+		return nil, fmt.Errorf("invalid layers %s: %w", path, err)
+	}
+	if err := layers.validate(); err != nil {
+		return nil, fmt.Errorf("invalid layers %s: %w", path, err)
+	}
+	return layers, nil
+}
+
+func (l Layers) validate() error {
+	known := make([]string, 0, len(builtinLayers)+len(l))
+	for _, b := range builtinLayers {
+		known = append(known, b.name)
+	}
+	for _, layer := range l {
+		if layer.Name == "" || slices.Contains(known, layer.Name) || layer.Name == "Others" || layer.Name == "Unclassified" {
+			return fmt.Errorf("invalid or duplicate layer name %q", layer.Name)
+		}
+		if !slices.Contains(known, layer.After) {
+			return fmt.Errorf("layer %q is after an unknown layer %q", layer.Name, layer.After)
+		}
+		if len(layer.Components) == 0 {
+			return fmt.Errorf("layer %q has no components", layer.Name)
+		}
+		for _, c := range layer.Components {
+			if c.Name == "" || len(c.Namespaces) == 0 {
+				return fmt.Errorf("layer %q has a component without a name or namespaces", layer.Name)
+			}
+		}
+		known = append(known, layer.Name)
+	}
+	return nil
+}
+
+// matcher maps the alerts to the components of the additional layers.
+func (l Layers) matcher(labels map[string]string) (layer, comp string, keys []string) {
+	namespace := labels["namespace"]
+	if namespace == "" {
+		return "", "", nil
+	}
+	for _, layer := range l {
+		for _, c := range layer.Components {
+			if slices.Contains(c.Namespaces, namespace) {
+				return layer.Name, c.Name, []string{"namespace"}
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// order returns the names of all the layers, from the most foundational one.
+func (l Layers) order() []string {
+	order := make([]string, 0, len(builtinLayers)+len(l))
+	for _, b := range builtinLayers {
+		order = append(order, b.name)
+	}
+	// Each layer goes right after the layer it follows, after any layers
+	// already placed there.
+	for _, layer := range l {
+		i := slices.Index(order, layer.After) + 1
+		for i < len(order) && l.isAfter(order[i], layer.After) {
+			i++
+		}
+		order = slices.Insert(order, i, layer.Name)
+	}
+	return order
+}
+
+// isAfter returns whether the additional layer is (transitively) placed
+// after the other layer.
+func (l Layers) isAfter(name, other string) bool {
+	for name != "" {
+		after := ""
+		for _, layer := range l {
+			if layer.Name == name {
+				after = layer.After
+			}
+		}
+		if after == other {
+			return true
+		}
+		name = after
+	}
+	return false
+}
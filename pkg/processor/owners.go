@@ -0,0 +1,176 @@
+package processor
+
+// This file contains logic for resolving the controllers owning the alerting pods.
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+const (
+	ownerKindLabel = "owner_kind"
+	ownerNameLabel = "owner_name"
+
+	// maxOwnerDepth limits walking the ownerReferences chain.
+	maxOwnerDepth = 5
+
+	// ownerErrorTTL is how long the failed lookups are cached, so that
+	// the API server is not queried for every alerting pod on every
+	// iteration while it's failing.
+	ownerErrorTTL = 30 * time.Second
+)
+
+// ownerResources maps the kinds of the controllers to their resources.
+//
+// The chain of the ownerReferences is only followed through these kinds.
+var ownerResources = map[string]schema.GroupVersionResource{
+	"ReplicaSet":  {Group: "apps", Version: "v1", Resource: "replicasets"},
+	"Deployment":  {Group: "apps", Version: "v1", Resource: "deployments"},
+	"StatefulSet": {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	"DaemonSet":   {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	"Job":         {Group: "batch", Version: "v1", Resource: "jobs"},
+	"CronJob":     {Group: "batch", Version: "v1", Resource: "cronjobs"},
+}
+
+var podResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// owner identifies the top-level controller of a pod.
+type owner struct {
+	kind string
+	name string
+}
+
+type cachedOwner struct {
+	owner
+	err     error
+	expires time.Time
+}
+
+// OwnerResolver resolves the top-level controllers of the pods referenced
+// by the alerts, by walking their ownerReferences.
+//
+// The lookups are cached to limit the load on the API server.
+type OwnerResolver struct {
+	client dynamic.Interface
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedOwner
+}
+
+// NewOwnerResolver creates a resolver caching the results for the ttl.
+func NewOwnerResolver(client dynamic.Interface, ttl time.Duration) *OwnerResolver {
+	return &OwnerResolver{
+		client: client,
+		ttl:    ttl,
+		cache:  make(map[string]cachedOwner),
+	}
+}
+
+// resolve returns the top-level controller of the pod.
+//
+// The zero owner is returned when the pod doesn't exist or is not
+// owned by a controller.
+func (r *OwnerResolver) resolve(ctx context.Context, namespace, pod string) (owner, error) {
+	key := namespace + "/" + pod
+	now := time.Now()
+
+	r.mu.Lock()
+	cached, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.owner, cached.err
+	}
+
+	o, err := r.lookup(ctx, namespace, pod)
+	entry := cachedOwner{owner: o, expires: now.Add(r.ttl)}
+	if err != nil {
+		entry = cachedOwner{err: err, expires: now.Add(min(r.ttl, ownerErrorTTL))}
+	}
+
+	r.mu.Lock()
+	r.cache[key] = entry
+	r.mu.Unlock()
+	return entry.owner, entry.err
+}
+
+// pruneCache removes the expired entries, as the pods come and go and
+// their names are rarely looked up again.
+func (r *OwnerResolver) pruneCache(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, cached := range r.cache {
+		if !now.Before(cached.expires) {
+			delete(r.cache, key)
+		}
+	}
+}
+
+func (r *OwnerResolver) lookup(ctx context.Context, namespace, pod string) (owner, error) {
+	obj, err := r.client.Resource(podResource).Namespace(namespace).Get(ctx, pod, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return owner{}, nil
+		}
+		return owner{}, err
+	}
+
+	var ret owner
+	for range maxOwnerDepth {
+		ref := metav1.GetControllerOfNoCopy(obj)
+		if ref == nil {
+			break
+		}
+		ret = owner{kind: ref.Kind, name: ref.Name}
+
+		gvr, ok := ownerResources[ref.Kind]
+		if !ok {
+			break
+		}
+		obj, err = r.client.Resource(gvr).Namespace(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				break
+			}
+			return owner{}, err
+		}
+	}
+	return ret, nil
+}
+
+// enrichAlerts adds the owner labels to the alerts referencing a pod.
+//
+// The labels are copied, as they are shared with the groups matchers.
+// Failing to resolve the owner leaves the alert as is.
+func (r *OwnerResolver) enrichAlerts(ctx context.Context, alerts []prom.Alert) {
+	r.pruneCache(time.Now())
+	for i, a := range alerts {
+		namespace, pod := a.Labels["namespace"], a.Labels["pod"]
+		if namespace == "" || pod == "" || a.Labels[ownerNameLabel] != "" {
+			continue
+		}
+
+		o, err := r.resolve(ctx, namespace, pod)
+		if err != nil {
+			slog.Warn("Failed to resolve the pod owner", "namespace", namespace, "pod", pod, "err", err)
+			continue
+		}
+		if o.name == "" {
+			continue
+		}
+		labels := maps.Clone(a.Labels)
+		labels[ownerKindLabel] = o.kind
+		labels[ownerNameLabel] = o.name
+		alerts[i].Labels = labels
+	}
+}
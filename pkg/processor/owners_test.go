@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+func newOwnedObject(apiVersion, kind, name, ownerKind, ownerName string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace("shop")
+	obj.SetName(name)
+	if ownerKind != "" {
+		controller := true
+		obj.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       ownerKind,
+			Name:       ownerName,
+			Controller: &controller,
+		}})
+	}
+	return obj
+}
+
+func TestOwnerResolver(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newOwnedObject("v1", "Pod", "checkout-7d9f-abcde", "ReplicaSet", "checkout-7d9f"),
+		newOwnedObject("apps/v1", "ReplicaSet", "checkout-7d9f", "Deployment", "checkout"),
+		newOwnedObject("apps/v1", "Deployment", "checkout", "", ""),
+		newOwnedObject("v1", "Pod", "standalone", "", ""),
+	)
+	resolver := NewOwnerResolver(client, time.Minute)
+
+	alerts := []prom.Alert{
+		{Name: "KubePodCrashLooping", Labels: map[string]string{
+			"alertname": "KubePodCrashLooping", "namespace": "shop", "pod": "checkout-7d9f-abcde"}},
+		{Name: "KubePodNotReady", Labels: map[string]string{
+			"alertname": "KubePodNotReady", "namespace": "shop", "pod": "standalone"}},
+		{Name: "KubePodNotReady", Labels: map[string]string{
+			"alertname": "KubePodNotReady", "namespace": "shop", "pod": "missing"}},
+	}
+	resolver.enrichAlerts(context.Background(), alerts)
+
+	assert.Equal(t, "Deployment", alerts[0].Labels[ownerKindLabel])
+	assert.Equal(t, "checkout", alerts[0].Labels[ownerNameLabel])
+	assert.NotContains(t, alerts[1].Labels, ownerNameLabel)
+	assert.NotContains(t, alerts[2].Labels, ownerNameLabel)
+
+	healthMaps := MapAlerts(alerts)
+	assert.Equal(t, "workload", healthMaps[0].Layer)
+	assert.Equal(t, "checkout", healthMaps[0].Component)
+	assert.Equal(t, "Others", healthMaps[1].Layer)
+
+	// The lookups are cached.
+	actions := len(client.Actions())
+	resolver.enrichAlerts(context.Background(), alerts[:1])
+	require.Equal(t, actions, len(client.Actions()))
+}
+
+// TestOwnerResolverCache tests the expired owners are removed from the cache
+// and the failed lookups are not repeated right away.
+func TestOwnerResolverCache(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	client.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("api server unavailable")
+	})
+	resolver := NewOwnerResolver(client, time.Minute)
+	resolver.cache["shop/gone-7d9f-abcde"] = cachedOwner{expires: time.Now().Add(-time.Second)}
+
+	alerts := []prom.Alert{{Name: "KubePodNotReady", Labels: map[string]string{
+		"alertname": "KubePodNotReady", "namespace": "shop", "pod": "checkout-7d9f-abcde"}}}
+	resolver.enrichAlerts(context.Background(), alerts)
+	assert.NotContains(t, alerts[0].Labels, ownerNameLabel)
+	assert.NotContains(t, resolver.cache, "shop/gone-7d9f-abcde")
+	require.Len(t, client.Actions(), 1)
+
+	resolver.enrichAlerts(context.Background(), alerts)
+	assert.Len(t, client.Actions(), 1)
+}
+
+// TestOwnerMappedGroupRestart tests the incidents of the alerts mapped to
+// their owners keep their group IDs after a restart.
+func TestOwnerMappedGroupRestart(t *testing.T) {
+	start := model.TimeFromUnixNano(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	var samples []model.SamplePair
+	for m := range 10 {
+		samples = append(samples, model.SamplePair{Timestamp: start.Add(time.Duration(m) * time.Minute), Value: 1})
+	}
+	labels := map[string]string{"alertname": "KubePodCrashLooping", "namespace": "shop",
+		"pod": "checkout-7d9f-abcde", "severity": "warning"}
+
+	// The health map exported before the restart, with the alert enriched
+	// by its owner.
+	enriched := map[string]string{ownerKindLabel: "Deployment", ownerNameLabel: "checkout", "group_id": "prev-group"}
+	for k, v := range labels {
+		enriched[k] = v
+	}
+	healthMap := MapAlerts([]prom.Alert{{Name: "KubePodCrashLooping", Labels: enriched}})[0]
+	require.Equal(t, "checkout", healthMap.Component)
+	healthMapRV := prom.RangeVector{{Metric: prom.LabelSet{Labels: healthMap.Labels()}, Samples: samples, Step: time.Minute}}
+
+	// The historical alerts don't carry the owner labels.
+	gc := &GroupsCollection{}
+	gc.processHistoricalAlerts(prom.RangeVector{
+		{Metric: prom.Alert{Name: "KubePodCrashLooping", Labels: labels}, Samples: samples, Step: time.Minute},
+	}, 0)
+	gc.UpdateGroupUUIDs(healthMapRV)
+
+	require.NotEmpty(t, gc.Groups)
+	for _, g := range gc.Groups {
+		assert.Equal(t, "prev-group", g.RootGroupID)
+	}
+}
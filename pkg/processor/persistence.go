@@ -0,0 +1,152 @@
+package processor
+
+// This file contains logic for persisting the groups collection across restarts.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/common/model"
+)
+
+// groupsSnapshotVersion is the version of the persisted groups format.
+// Snapshots of other versions are ignored.
+const groupsSnapshotVersion = 1
+
+// groupsSnapshot is the persisted form of the groups collection.
+type groupsSnapshot struct {
+	Version int `json:"version"`
+	// ConfigHash is the hash of the grouping configuration the groups were
+	// created with. The groups are not reused with a different configuration.
+	ConfigHash string              `json:"config_hash"`
+	Groups     []groupMatcherState `json:"groups"`
+}
+
+// groupMatcherState is the persisted form of a GroupMatcher.
+type groupMatcherState struct {
+	GroupID     string              `json:"group_id"`
+	RootGroupID string              `json:"root_group_id"`
+	Start       model.Time          `json:"start"`
+	Modified    model.Time          `json:"modified"`
+	End         model.Time          `json:"end"`
+	Distance    snapshotDistance    `json:"distance"`
+	Matchers    []map[string]string `json:"matchers"`
+}
+
+// snapshotDistance encodes the infinite distance of the root groups,
+// which is not supported by JSON, as "inf".
+type snapshotDistance float64
+
+func (d snapshotDistance) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(d), 1) {
+		return []byte(`"inf"`), nil
+	}
+	return json.Marshal(float64(d))
+}
+
+func (d *snapshotDistance) UnmarshalJSON(data []byte) error {
+	if string(data) == `"inf"` {
+		*d = snapshotDistance(math.Inf(1))
+		return nil
+	}
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	*d = snapshotDistance(f)
+	return nil
+}
+
+// SaveGroups writes the groups of the collection to the file.
+//
+// The file is replaced atomically, so that a crash while saving doesn't
+// leave a corrupted snapshot behind.
+func (gc *GroupsCollection) SaveGroups(path string) error {
+	snapshot := groupsSnapshot{
+		Version:    groupsSnapshotVersion,
+		ConfigHash: gc.Config.Hash(),
+		Groups:     make([]groupMatcherState, 0, len(gc.Groups)),
+	}
+	for _, g := range gc.Groups {
+		var matchers []map[string]string
+		for _, m := range g.Matchers {
+			matchers = append(matchers, m.Labels)
+		}
+		snapshot.Groups = append(snapshot.Groups, groupMatcherState{
+			GroupID:     g.GroupID,
+			RootGroupID: g.RootGroupID,
+			Start:       g.Start,
+			Modified:    g.Modified,
+			End:         g.End,
+			Distance:    snapshotDistance(g.Distance),
+			Matchers:    matchers,
+		})
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadGroups replaces the groups of the collection with the ones saved
+// in the file.
+//
+// It fails when the snapshot has a different version or was created with
+// a different grouping configuration, in which case the collection is
+// expected to be rebuilt from the history.
+func (gc *GroupsCollection) LoadGroups(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var snapshot groupsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("invalid groups snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != groupsSnapshotVersion {
+		return fmt.Errorf("unsupported groups snapshot version %d", snapshot.Version)
+	}
+	if hash := gc.Config.Hash(); snapshot.ConfigHash != hash {
+		return fmt.Errorf("groups snapshot created with a different grouping configuration (%s, current %s)",
+			snapshot.ConfigHash, hash)
+	}
+
+	groups := make([]*GroupMatcher, 0, len(snapshot.Groups))
+	for _, s := range snapshot.Groups {
+		var matchers []labelsSubsetMatcher
+		for _, labels := range s.Matchers {
+			matchers = append(matchers, labelsSubsetMatcher{Labels: labels})
+		}
+		groups = append(groups, &GroupMatcher{
+			GroupID:     s.GroupID,
+			RootGroupID: s.RootGroupID,
+			Start:       s.Start,
+			Modified:    s.Modified,
+			End:         s.End,
+			Distance:    float64(s.Distance),
+			Matchers:    matchers,
+		})
+	}
+	gc.Groups = groups
+	gc.timeMatchIndex = nil
+	return nil
+}
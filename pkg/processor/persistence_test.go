@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+// TestGroupsCollectionSaveLoadGroups tests the groups survive the round-trip
+// through the state file, including the infinite distances.
+func TestGroupsCollectionSaveLoadGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.json")
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	gc := GroupsCollection{Config: GroupingConfig{MaxGroups: 10}}
+	alerts := gc.ProcessAlertsBatch([]prom.Alert{
+		{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "ns1"}},
+	}, start)
+	require.NoError(t, gc.SaveGroups(path))
+
+	loaded := GroupsCollection{Config: GroupingConfig{MaxGroups: 10}}
+	require.NoError(t, loaded.LoadGroups(path))
+	assert.Equal(t, gc.Groups, loaded.Groups)
+	assert.Contains(t, loaded.Groups, &GroupMatcher{
+		GroupID:     alerts[0].Labels["group_id"],
+		RootGroupID: alerts[0].Labels["group_id"],
+		Start:       model.TimeFromUnixNano(start.UnixNano()),
+		Modified:    model.TimeFromUnixNano(start.UnixNano()),
+		End:         model.TimeFromUnixNano(start.UnixNano()),
+		Distance:    math.Inf(1),
+	})
+
+	// The loaded groups keep matching the alerts.
+	later := loaded.ProcessAlertsBatch([]prom.Alert{
+		{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "ns1"}},
+	}, start.Add(time.Hour))
+	assert.Equal(t, alerts[0].Labels["group_id"], later[0].Labels["group_id"])
+}
+
+// TestGroupsCollectionLoadGroupsInvalid tests unusable state files are rejected.
+func TestGroupsCollectionLoadGroupsInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	gc := GroupsCollection{}
+	assert.Error(t, gc.LoadGroups(filepath.Join(dir, "missing.json")))
+
+	corrupted := filepath.Join(dir, "corrupted.json")
+	require.NoError(t, os.WriteFile(corrupted, []byte("{not json"), 0o644))
+	assert.Error(t, gc.LoadGroups(corrupted))
+
+	version := filepath.Join(dir, "version.json")
+	require.NoError(t, os.WriteFile(version, []byte(`{"version":2}`), 0o644))
+	assert.Error(t, gc.LoadGroups(version))
+
+	// Groups created with a different configuration are not reused.
+	configured := filepath.Join(dir, "configured.json")
+	require.NoError(t, (&GroupsCollection{Config: GroupingConfig{MaxGroups: 5}}).SaveGroups(configured))
+	assert.Error(t, gc.LoadGroups(configured))
+}
@@ -4,46 +4,319 @@ package processor
 
 import (
 	"context"
+	"io"
 	"log/slog"
+	"math"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+// groupingConfigHashLabel identifies the grouping configuration the incidents
+// in the health map were grouped with.
+const groupingConfigHashLabel = "grouping_config_hash"
+
 // processor is the component responsible for continuously loading alerts from source
 // and coordinates updating the exported metrics.
 type processor struct {
-	// healthMapMetrics maps input signal (alerts) to components, incidents
-	// and normalized severity.
-	healthMapMetrics prom.MetricSet
-
-	// componentsMetrics maps components to their ranking via the metric value.
-	componentsMetrics prom.MetricSet
+	metrics Metrics
 
 	// interval is the time interval between processing iterations.
 	interval time.Duration
 
-	loader           *prom.Loader
+	// grouping configures the grouping of alerts into incidents.
+	grouping GroupingConfig
+
+	// nodeThresholds configures escalation of the compute health.
+	nodeThresholds NodeThresholds
+
+	// groupsStateFile is the file the groups are persisted to, if set.
+	groupsStateFile string
+
+	// warmUpWindow limits the historical alerts creating the groups
+	// on start to the recent ones. Zero means the whole history.
+	warmUpWindow time.Duration
+
+	// groupingAuditLog receives the grouping decisions, if set.
+	groupingAuditLog io.Writer
+
+	// severityRemap overrides the severities of the alerts per namespace.
+	severityRemap SeverityRemap
+
+	// debugMappings keeps the alerts of the last iteration to explain
+	// their mapping to the components.
+	debugMappings bool
+	lastAlerts    lastAlerts
+
+	// operatorConditions maps the cluster operator conditions as
+	// another source of the components health.
+	operatorConditions bool
+
+	// othersMode handles the grouped alerts not mapped to any component.
+	othersMode OthersMode
+
+	// componentLayers are the additional layers of components.
+	componentLayers Layers
+
+	// componentTiers escalates the incidents touching critical components.
+	componentTiers ComponentTiers
+
+	// dedupExcludedLabels are the health map labels ignored when deduplicating.
+	dedupExcludedLabels []string
+
+	// metricsPrefix is the prefix of the names of the exported metrics.
+	metricsPrefix string
+
+	// ownerResolver resolves the controllers of the alerting pods, if set.
+	ownerResolver *OwnerResolver
+
+	// suppressionRules drop the matching alerts before the processing.
+	suppressionRules []SuppressionRule
+
+	// timeSkewThreshold is the clock difference to Prometheus reported
+	// as a warning.
+	timeSkewThreshold time.Duration
+
+	loader *prom.Loader
+
+	// groupsMu guards the groups collection, as it's pruned independently
+	// of the processing when pruneInterval is set.
+	groupsMu         sync.Mutex
 	groupsCollection *GroupsCollection
+
+	// pruneInterval is the interval of pruning the groups. Zero means
+	// pruning in each processing iteration.
+	pruneInterval time.Duration
+
+	// events distributes the changes of the incidents, if set.
+	events *IncidentEvents
+	// incidents are the states of the incidents from the last iteration.
+	incidents map[string]incidentState
+
+	// status is the outcome of the processing iterations.
+	status processingStatus
+
+	// layers is the summary of the incidents per layer from the last iteration.
+	layers layerSummaries
+
+	// activeGroups are the group IDs of the alerts from the last iteration.
+	activeGroups map[string]struct{}
+}
+
+// DefaultMetricsPrefix is the prefix of the names of the exported metrics.
+const DefaultMetricsPrefix = "cluster:health:"
+
+// Names of the exported metrics, relative to the metrics prefix.
+const (
+	ComponentsMapMetric    = "components:map"
+	ComponentsMetric       = "components"
+	GroupingStatsMetric    = "grouping:stats"
+	GroupsCountMetric      = "groups:count"
+	WeightedSeverityMetric = "weighted_severity"
+	SuppressedAlertsMetric = "suppressed_alerts"
+	TimeSkewMetric         = "time_skew_seconds"
+	UnmappedAlertsMetric   = "unmapped_alerts"
+	GroupsEvictedMetric    = "groups_evicted_total"
+)
+
+// MetricName returns the full name of the exported metric.
+//
+// The DefaultMetricsPrefix is used when the prefix is empty.
+func MetricName(prefix, name string) string {
+	if prefix == "" {
+		prefix = DefaultMetricsPrefix
+	}
+	return prefix + name
+}
+
+// Metrics holds the metrics updated by the processor.
+type Metrics struct {
+	// HealthMap maps input signal (alerts) to components, incidents
+	// and normalized severity.
+	HealthMap prom.MetricSet
+
+	// Components maps components to their ranking via the metric value.
+	Components prom.MetricSet
+
+	// Grouping exposes statistics about the quality of the alerts grouping.
+	Grouping prom.MetricSet
+
+	// Groups exposes the number of the groups tracked for matching,
+	// by the distance of their matchers.
+	Groups prom.MetricSet
+
+	// WeightedSeverity exposes the sum of the severities of the active
+	// incidents, as a single score of the cluster health.
+	WeightedSeverity prom.MetricSet
+
+	// Suppressed exposes the number of alerts dropped by the suppression rules.
+	Suppressed prom.MetricSet
+
+	// Unmapped exposes the number of alerts not mapped to any component,
+	// by their name and namespace.
+	Unmapped prom.MetricSet
+
+	// TimeSkew exposes the difference between the local clock and the
+	// Prometheus clock in seconds.
+	TimeSkew prom.MetricSet
+
+	// GroupsEvicted counts the groups evicted due to the groups limit.
+	GroupsEvicted prometheus.Counter
+}
+
+// ProcessorConfig holds the settings of the processor.
+type ProcessorConfig struct {
+	// Interval is the time interval between processing iterations.
+	Interval time.Duration
+
+	// PromURL is the URL of the Prometheus server to load the alerts from.
+	PromURL string
+
+	// MetricsPrefix is the prefix of the names of the exported metrics.
+	// Defaults to DefaultMetricsPrefix.
+	MetricsPrefix string
+
+	// PromConfig holds the connection settings for Prometheus.
+	// The zero value assumes running in-cluster.
+	PromConfig prom.LoaderConfig
+
+	// Grouping configures the grouping of alerts into incidents.
+	Grouping GroupingConfig
+
+	// NodeThresholds configures escalation of the compute health based
+	// on the number of affected nodes.
+	NodeThresholds NodeThresholds
+
+	// TimeSkewThreshold is the difference between the local clock and
+	// the Prometheus clock above which a warning is logged.
+	TimeSkewThreshold time.Duration
+
+	// Events receives the changes of the incidents detected in each
+	// iteration. Disabled when nil.
+	Events *IncidentEvents
+
+	// OwnerResolver maps the alerts referencing pods to the workloads
+	// of their owning controllers. Disabled when nil.
+	OwnerResolver *OwnerResolver
+
+	// SuppressionRules drop the matching alerts before they are grouped
+	// into incidents. The rules apply to the currently firing alerts only,
+	// not to the historical alerts loaded on start.
+	SuppressionRules []SuppressionRule
+
+	// SeverityRemap overrides the severities of the alerts per namespace.
+	// No remapping when empty.
+	SeverityRemap SeverityRemap
+
+	// DebugMappings keeps the alerts of the last iteration to explain
+	// their mapping to the components, see Mappings.
+	DebugMappings bool
+
+	// OperatorConditions maps the conditions of the cluster operators
+	// to the health of their components, next to the alerts.
+	OperatorConditions bool
+
+	// OthersMode configures how the grouped alerts not mapped to any
+	// component are reported. Defaults to OthersKeep.
+	OthersMode OthersMode
+
+	// Layers are the additional layers the alerts are mapped to, on top
+	// of the built-in ones.
+	Layers Layers
+
+	// ComponentTiers escalates the incidents touching the critical
+	// components, see [ComponentTiers].
+	ComponentTiers ComponentTiers
+
+	// GroupsStateFile is the file the groups are persisted to after each
+	// iteration. On start, the groups are loaded from it instead of being
+	// rebuilt from the history, unless it's missing or unusable.
+	// Disabled when empty.
+	GroupsStateFile string
+
+	// PruneInterval is the interval of pruning the old groups, independently
+	// of the processing interval. Zero means pruning in each iteration.
+	PruneInterval time.Duration
+
+	// WarmUpWindow limits the historical alerts creating the groups on start
+	// to the ones active within the window before the start. The whole
+	// loaded history is used to keep the group IDs. Zero means no limit.
+	WarmUpWindow time.Duration
+
+	// GroupingAuditLog receives the grouping decisions as JSON lines.
+	// Disabled when nil.
+	GroupingAuditLog io.Writer
+
+	// DedupExcludedLabels are the exported health map labels (e.g. src_pod)
+	// ignored when deduplicating the health maps, so that the entries
+	// differing only in volatile labels are merged.
+	DedupExcludedLabels []string
 }
 
-func NewProcessor(healthMapMetrics, componentsMetrics prom.MetricSet, interval time.Duration, promURL string) (*processor, error) {
-	promLoader, err := prom.NewLoader(promURL)
+func NewProcessor(metrics Metrics, cfg ProcessorConfig) (*processor, error) {
+	promLoader, err := prom.NewLoader(cfg.PromURL, cfg.PromConfig)
 	if err != nil {
 		return nil, err
 	}
+	suppressionRules := slices.Clone(cfg.SuppressionRules)
+	for i := range suppressionRules {
+		if err := suppressionRules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
 	return &processor{
-		healthMapMetrics:  healthMapMetrics,
-		componentsMetrics: componentsMetrics,
-		interval:          interval,
-		loader:            promLoader,
+		metrics:             metrics,
+		interval:            cfg.Interval,
+		grouping:            cfg.Grouping,
+		nodeThresholds:      cfg.NodeThresholds,
+		timeSkewThreshold:   cfg.TimeSkewThreshold,
+		suppressionRules:    suppressionRules,
+		ownerResolver:       cfg.OwnerResolver,
+		metricsPrefix:       cfg.MetricsPrefix,
+		events:              cfg.Events,
+		severityRemap:       cfg.SeverityRemap,
+		componentTiers:      cfg.ComponentTiers,
+		componentLayers:     cfg.Layers,
+		othersMode:          cfg.OthersMode,
+		operatorConditions:  cfg.OperatorConditions,
+		debugMappings:       cfg.DebugMappings,
+		groupingAuditLog:    cfg.GroupingAuditLog,
+		warmUpWindow:        cfg.WarmUpWindow,
+		groupsStateFile:     cfg.GroupsStateFile,
+		pruneInterval:       cfg.PruneInterval,
+		dedupExcludedLabels: cfg.DedupExcludedLabels,
+		loader:              promLoader,
 	}, nil
 }
 
 // Start starts the processor in a goroutine and returns immediately.
 func (p *processor) Start(ctx context.Context) {
 	go p.Run(ctx)
+	if p.pruneInterval > 0 {
+		go p.runPruning(ctx)
+	}
+}
+
+// runPruning prunes the groups every pruneInterval and blocks until
+// canceled via the ctx.
+func (p *processor) runPruning(ctx context.Context) {
+	ticker := time.NewTicker(p.pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			p.groupsMu.Lock()
+			p.pruneGroups(t)
+			p.groupsMu.Unlock()
+		}
+	}
 }
 
 // initGroupsCollection initializes the groups collection by loading the alerts.
@@ -52,7 +325,23 @@ func (p *processor) Start(ctx context.Context) {
 // for assigning group-ids to the alerts.
 func (p *processor) InitGroupsCollection(ctx context.Context, start, end time.Time, step time.Duration) error {
 	slog.Info("Initializing groups collection", "start", start, "end", end, "step", step)
-	p.groupsCollection = &GroupsCollection{}
+	// The historical alerts are compared against the local time, check
+	// the clocks agree before using them.
+	p.updateTimeSkew(ctx)
+
+	p.groupsCollection = &GroupsCollection{Config: p.grouping, layers: p.componentLayers}
+	if p.groupingAuditLog != nil {
+		p.groupsCollection.SetAuditLog(p.groupingAuditLog)
+	}
+
+	if p.groupsStateFile != "" {
+		err := p.groupsCollection.LoadGroups(p.groupsStateFile)
+		if err == nil {
+			slog.Info("Loaded groups from state file", "file", p.groupsStateFile, "groups", len(p.groupsCollection.Groups))
+			return nil
+		}
+		slog.Warn("Failed to load groups from state file, rebuilding from history", "file", p.groupsStateFile, "err", err)
+	}
 
 	slog.Info("Loading alerts range")
 	alertsRange, err := p.loader.LoadAlertsRange(ctx, start, end, step)
@@ -61,12 +350,18 @@ func (p *processor) InitGroupsCollection(ctx context.Context, start, end time.Ti
 	}
 	slog.Info("Loaded alerts range", "len", len(alertsRange))
 
-	// Warm up the groups collection with historical alerts.
-	slog.Info("Processing historical alerts")
-	p.groupsCollection.processHistoricalAlerts(alertsRange)
+	// Warm up the groups collection with historical alerts. The health map
+	// is still loaded for the whole range to keep the group IDs of the
+	// older incidents.
+	var cutoff model.Time
+	if p.warmUpWindow > 0 {
+		cutoff = model.TimeFromUnixNano(end.Add(-p.warmUpWindow).UnixNano())
+	}
+	slog.Info("Processing historical alerts", "cutoff", cutoff.Time())
+	p.groupsCollection.processHistoricalAlerts(alertsRange, cutoff)
 
 	slog.Info("Loading health map range")
-	healthMapRV, err := p.loader.LoadVectorRange(ctx, "cluster:health:components:map", start, end, step)
+	healthMapRV, err := p.loader.LoadVectorRange(ctx, MetricName(p.metricsPrefix, ComponentsMapMetric), start, end, step)
 	if err != nil {
 		return err
 	}
@@ -91,6 +386,7 @@ func (p *processor) Run(ctx context.Context) {
 				slog.Info("Start processing")
 
 				err := p.Process(ctx)
+				p.status.set(err)
 				if err != nil {
 					slog.Error("Error processing", "err", err)
 					// We don't return an error here because we want to keep retrying.
@@ -108,16 +404,18 @@ func (p *processor) Run(ctx context.Context) {
 
 // dedupHealthMaps deduplicates the health maps by combining the health values.
 //
-// The deduplication is done by hashing the label values of the health maps.
-// For duplicates, the health value is combined by taking the maximum of the two.
-func dedupHealthMaps(healthMaps []ComponentHealthMap) []ComponentHealthMap {
+// The deduplication is done by hashing the label values of the health maps,
+// except for the excluded labels. For duplicates, the health value is combined
+// by taking the maximum of the two, keeping the labels of the first one.
+func dedupHealthMaps(healthMaps []ComponentHealthMap, excluded []string) []ComponentHealthMap {
 	hashMap := make(map[uint64]ComponentHealthMap, len(healthMaps))
 
 	for _, healthMap := range healthMaps {
-		hash := healthMap.hashLabelValues()
+		hash := healthMap.hashLabelValues(excluded...)
 		existing, ok := hashMap[hash]
 		if ok {
 			existing.Health = max(existing.Health, healthMap.Health)
+			hashMap[hash] = existing
 		} else {
 			hashMap[hash] = healthMap
 		}
@@ -131,15 +429,86 @@ func dedupHealthMaps(healthMaps []ComponentHealthMap) []ComponentHealthMap {
 }
 
 func (p *processor) assignAlertsToGroups(alerts []prom.Alert, t time.Time) []prom.Alert {
+	p.groupsMu.Lock()
+	defer p.groupsMu.Unlock()
+
 	processedAlerts := p.groupsCollection.ProcessAlertsBatch(alerts, t)
 
-	// Prune the groups collection to remove old groups.
-	p.groupsCollection.PruneGroups(t)
+	if p.pruneInterval <= 0 {
+		p.pruneGroups(t)
+	}
+	p.metrics.Groups.Update(groupsCount(p.groupsCollection.Groups))
 	return processedAlerts
 }
 
+// pruneGroups removes the old groups from the groups collection.
+// It expects the groupsMu to be held.
+func (p *processor) pruneGroups(t time.Time) {
+	evicted := p.groupsCollection.PruneGroups(t)
+	if evicted > 0 {
+		slog.Warn("Evicted groups due to the groups limit", "evicted", evicted)
+		p.metrics.GroupsEvicted.Add(float64(evicted))
+	}
+}
+
+// saveGroups persists the groups, if enabled. A failure doesn't stop
+// the processing, the groups are rebuilt from the history on restart.
+func (p *processor) saveGroups() {
+	if p.groupsStateFile == "" {
+		return
+	}
+	p.groupsMu.Lock()
+	defer p.groupsMu.Unlock()
+	if err := p.groupsCollection.SaveGroups(p.groupsStateFile); err != nil {
+		slog.Error("Failed to save groups to state file", "file", p.groupsStateFile, "err", err)
+	}
+}
+
+func (p *processor) suppressAlerts(alerts []prom.Alert, t time.Time) []prom.Alert {
+	alerts, suppressed := suppressAlerts(p.suppressionRules, alerts, t)
+
+	metrics := make([]prom.Metric, 0, len(suppressed))
+	for rule, count := range suppressed {
+		metrics = append(metrics, prom.Metric{
+			Labels: map[string]string{"rule": rule},
+			Value:  float64(count),
+		})
+	}
+	p.metrics.Suppressed.Update(metrics)
+	return alerts
+}
+
+// unmappedAlerts counts the alerts mapped to the Others component, by their
+// name and namespace, to find the alerts missing a mapping.
+func unmappedAlerts(healthMaps []ComponentHealthMap) []prom.Metric {
+	type key struct{ alertname, namespace string }
+	counts := make(map[key]int)
+	var keys []key
+	for _, hm := range healthMaps {
+		if hm.SrcType != Alert || hm.Component != "Others" {
+			continue
+		}
+		k := key{hm.SrcLabels["alertname"], hm.SrcLabels["namespace"]}
+		if _, ok := counts[k]; !ok {
+			keys = append(keys, k)
+		}
+		counts[k]++
+	}
+
+	metrics := make([]prom.Metric, 0, len(keys))
+	for _, k := range keys {
+		metrics = append(metrics, prom.Metric{
+			Labels: map[string]string{"alertname": k.alertname, "namespace": k.namespace},
+			Value:  float64(counts[k]),
+		})
+	}
+	return metrics
+}
+
 // Process performs a single iteration of the processor.
 func (p *processor) Process(ctx context.Context) error {
+	p.updateTimeSkew(ctx)
+
 	err := p.updateHealthMap(ctx)
 	if err != nil {
 		return err
@@ -157,27 +526,298 @@ func (p *processor) updateHealthMap(ctx context.Context) error {
 		return err
 	}
 
+	alerts = p.suppressAlerts(alerts, t)
+
 	if p.groupsCollection != nil {
 		alerts = p.assignAlertsToGroups(alerts, t)
+		p.updateGroupingMetrics(alerts)
+		p.saveGroups()
 	}
 
-	alertsHealthMap := MapAlerts(alerts)
-	alertsHealthMap = dedupHealthMaps(alertsHealthMap)
+	if p.ownerResolver != nil {
+		p.ownerResolver.enrichAlerts(ctx, alerts)
+	}
 
+	if p.debugMappings {
+		p.lastAlerts.set(alerts)
+	}
+
+	alertsHealthMap := p.componentLayers.MapAlerts(alerts)
+	p.metrics.Unmapped.Update(unmappedAlerts(alertsHealthMap))
+	remapSeverities(alerts, alertsHealthMap, p.severityRemap)
+	escalateComputeHealth(alerts, alertsHealthMap, p.nodeThresholds)
+	if p.operatorConditions {
+		// The conditions only complement the alerts, keep updating the alerts
+		// health maps without them.
+		conditions, err := p.loader.LoadVector(ctx, clusterOperatorConditionsQuery, t)
+		if err != nil {
+			slog.Error("Failed to load the cluster operator conditions", "err", err)
+		} else {
+			alertsHealthMap = append(alertsHealthMap, MapClusterOperatorConditions(conditions)...)
+		}
+	}
+	escalateComponentTiers(alertsHealthMap, p.componentTiers)
+	alertsHealthMap = dedupHealthMaps(alertsHealthMap, p.dedupExcludedLabels)
+	alertsHealthMap = applyOthersMode(alertsHealthMap, p.othersMode)
+
+	p.metrics.WeightedSeverity.Update([]prom.Metric{
+		{Labels: map[string]string{}, Value: weightedSeverity(alertsHealthMap)},
+	})
+	p.publishIncidentEvents(alertsHealthMap, t)
+	p.layers.set(SummarizeLayers(alertsHealthMap))
+
+	configHash := p.grouping.Hash()
 	metrics := make([]prom.Metric, 0, len(alertsHealthMap))
 	for _, healthMap := range alertsHealthMap {
+		labels := healthMap.Labels()
+		labels[groupingConfigHashLabel] = configHash
 		metrics = append(metrics, prom.Metric{
-			Labels: healthMap.Labels(),
+			Labels: labels,
 			Value:  float64(healthMap.Health),
 		})
 	}
-	p.healthMapMetrics.Update(metrics)
+	p.metrics.HealthMap.Update(metrics)
 
 	return nil
 }
 
+// ProcessingStatus is the outcome of the processing iterations.
+type ProcessingStatus struct {
+	// LastSuccess is the time of the last successful iteration,
+	// zero if none succeeded yet.
+	LastSuccess time.Time
+	// LastError is the error of the last iteration, nil if it succeeded.
+	LastError error
+}
+
+type processingStatus struct {
+	mu     sync.RWMutex
+	status ProcessingStatus
+}
+
+func (s *processingStatus) set(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastError = err
+	if err == nil {
+		s.status.LastSuccess = time.Now()
+	}
+}
+
+// Status returns the outcome of the processing iterations.
+func (p *processor) Status() ProcessingStatus {
+	p.status.mu.RLock()
+	defer p.status.mu.RUnlock()
+	return p.status.status
+}
+
+// Mappings explains the components the alerts from the last processing
+// iteration were mapped to. It's nil unless enabled via DebugMappings.
+func (p *processor) Mappings() []AlertMapping {
+	alerts := p.lastAlerts.get()
+	if alerts == nil {
+		return nil
+	}
+	return p.componentLayers.ExplainMappings(alerts)
+}
+
+// lastAlerts holds the alerts from the last processing iteration.
+type lastAlerts struct {
+	mu     sync.RWMutex
+	alerts []prom.Alert
+}
+
+func (l *lastAlerts) set(alerts []prom.Alert) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts = alerts
+}
+
+func (l *lastAlerts) get() []prom.Alert {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.alerts
+}
+
+// Layers returns the summary of the active incidents per layer from
+// the last processing iteration.
+func (p *processor) Layers() []LayerSummary {
+	return p.layers.get()
+}
+
+// timeLoader loads the current time from the source of the alerts.
+type timeLoader interface {
+	LoadTime(ctx context.Context) (time.Time, error)
+}
+
+// checkTimeSkew returns the difference between the local clock and the clock
+// of the source.
+//
+// A warning is logged when the difference exceeds the threshold.
+func checkTimeSkew(ctx context.Context, l timeLoader, threshold time.Duration) (time.Duration, error) {
+	before := time.Now()
+	remote, err := l.LoadTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	after := time.Now()
+
+	// Compare to the middle of the request to compensate for the latency.
+	local := before.Add(after.Sub(before) / 2)
+	skew := local.Sub(remote)
+
+	if skew.Abs() > threshold {
+		slog.Warn("Time skew between the analyzer and Prometheus exceeds the threshold",
+			"skew", skew, "threshold", threshold)
+	}
+	return skew, nil
+}
+
+// updateTimeSkew updates the time skew metric.
+//
+// Failing to determine the time skew doesn't prevent the processing.
+func (p *processor) updateTimeSkew(ctx context.Context) {
+	skew, err := checkTimeSkew(ctx, p.loader, p.timeSkewThreshold)
+	if err != nil {
+		slog.Error("Failed to check the time skew", "err", err)
+		p.metrics.TimeSkew.Update(nil)
+		return
+	}
+	p.metrics.TimeSkew.Update([]prom.Metric{
+		{Labels: map[string]string{}, Value: skew.Seconds()},
+	})
+}
+
+// publishIncidentEvents publishes the changes of the incidents since
+// the last iteration.
+func (p *processor) publishIncidentEvents(healthMaps []ComponentHealthMap, t time.Time) {
+	if p.events == nil {
+		return
+	}
+	incidents := incidentStates(healthMaps)
+	p.events.Publish(diffIncidents(p.incidents, incidents, t))
+	p.incidents = incidents
+}
+
+// weightedSeverity sums the severities of the incidents, using the health
+// value as the weight.
+//
+// The severity of an incident is the highest health value of its health maps.
+// Health maps without a group ID are treated as separate incidents.
+func weightedSeverity(healthMaps []ComponentHealthMap) float64 {
+	var sum float64
+	groups := make(map[string]HealthValue)
+	for _, healthMap := range healthMaps {
+		if healthMap.GroupId == "" {
+			sum += float64(healthMap.Health)
+			continue
+		}
+		groups[healthMap.GroupId] = max(groups[healthMap.GroupId], healthMap.Health)
+	}
+	for _, health := range groups {
+		sum += float64(health)
+	}
+	return sum
+}
+
+// groupingStats holds statistics about the quality of the alerts grouping.
+type groupingStats struct {
+	// avgIncidentSize is the average number of alerts per group.
+	avgIncidentSize float64
+	// singleAlertIncidents is the number of groups with a single alert.
+	singleAlertIncidents int
+	// churn is the number of groups opened and closed since the last iteration.
+	churn int
+}
+
+// computeGroupingStats calculates the grouping statistics for the alerts
+// with assigned groups.
+//
+// It returns the statistics and the set of the groups, to be passed as
+// prevGroups in the next iteration.
+func computeGroupingStats(alerts []prom.Alert, prevGroups map[string]struct{}) (
+	groupingStats, map[string]struct{}) {
+	groupSizes := make(map[string]int)
+	grouped := 0
+	for _, a := range alerts {
+		if groupID := a.Labels["group_id"]; groupID != "" {
+			groupSizes[groupID]++
+			grouped++
+		}
+	}
+
+	var stats groupingStats
+	groups := make(map[string]struct{}, len(groupSizes))
+	for groupID, size := range groupSizes {
+		groups[groupID] = struct{}{}
+		if size == 1 {
+			stats.singleAlertIncidents++
+		}
+		if _, ok := prevGroups[groupID]; !ok {
+			// Opened group.
+			stats.churn++
+		}
+	}
+	for groupID := range prevGroups {
+		if _, ok := groups[groupID]; !ok {
+			// Closed group.
+			stats.churn++
+		}
+	}
+	if len(groupSizes) > 0 {
+		stats.avgIncidentSize = float64(grouped) / float64(len(groupSizes))
+	}
+	return stats, groups
+}
+
+func (p *processor) updateGroupingMetrics(alerts []prom.Alert) {
+	var stats groupingStats
+	stats, p.activeGroups = computeGroupingStats(alerts, p.activeGroups)
+
+	p.metrics.Grouping.Update([]prom.Metric{
+		{Labels: map[string]string{"stat": "avg_incident_size"}, Value: stats.avgIncidentSize},
+		{Labels: map[string]string{"stat": "single_alert_incidents"}, Value: float64(stats.singleAlertIncidents)},
+		{Labels: map[string]string{"stat": "churn"}, Value: float64(stats.churn)},
+	})
+}
+
+// groupsCount returns the number of the distinct groups, in total and
+// by the kind of their matchers: direct (same alert), fuzzy (related labels)
+// and time (close in time). A group with matchers of multiple kinds is
+// counted in each of them.
+func groupsCount(groups []*GroupMatcher) []prom.Metric {
+	all := make(map[string]struct{})
+	byDistance := map[string]map[string]struct{}{
+		"direct": {},
+		"fuzzy":  {},
+		"time":   {},
+	}
+	for _, g := range groups {
+		all[g.RootGroupID] = struct{}{}
+		switch {
+		case g.Distance == 0:
+			byDistance["direct"][g.RootGroupID] = struct{}{}
+		case math.IsInf(g.Distance, 1):
+			byDistance["time"][g.RootGroupID] = struct{}{}
+		default:
+			byDistance["fuzzy"][g.RootGroupID] = struct{}{}
+		}
+	}
+
+	metrics := []prom.Metric{
+		{Labels: map[string]string{"distance": "all"}, Value: float64(len(all))},
+	}
+	for _, distance := range []string{"direct", "fuzzy", "time"} {
+		metrics = append(metrics, prom.Metric{
+			Labels: map[string]string{"distance": distance},
+			Value:  float64(len(byDistance[distance])),
+		})
+	}
+	return metrics
+}
+
 func (p *processor) updateComponentsMetrics() {
-	ranks := BuildComponentRanks()
+	ranks := p.componentLayers.BuildComponentRanks()
 
 	metrics := make([]prom.Metric, 0)
 	for _, r := range ranks {
@@ -189,7 +829,7 @@ func (p *processor) updateComponentsMetrics() {
 			Value: float64(r.Rank),
 		})
 	}
-	p.componentsMetrics.Update(metrics)
+	p.metrics.Components.Update(metrics)
 }
 
 type ComponentRank struct {
@@ -198,16 +838,44 @@ type ComponentRank struct {
 	Rank      int
 }
 
+// BuildComponentRanks ranks the components of the built-in layers.
 func BuildComponentRanks() []ComponentRank {
-	components := make(map[string]ComponentRank)
-	components["compute"] = ComponentRank{Layer: "compute", Component: "compute", Rank: 1}
+	return Layers(nil).BuildComponentRanks()
+}
 
-	for i, m := range coreMatchers {
-		components[m.component] = ComponentRank{Layer: "core", Component: m.component, Rank: 10 + i*5}
+// BuildComponentRanks ranks the components of the built-in and the additional
+// layers, in the order of the layers.
+func (l Layers) BuildComponentRanks() []ComponentRank {
+	layerComponents := map[string][]string{"compute": {"compute"}}
+	for _, m := range coreMatchers {
+		layerComponents["core"] = append(layerComponents["core"], m.component)
+	}
+	for _, m := range workloadMatchers {
+		layerComponents["workload"] = append(layerComponents["workload"], m.component)
+	}
+	baseRanks := make(map[string]int, len(builtinLayers))
+	for _, b := range builtinLayers {
+		baseRanks[b.name] = b.baseRank
+	}
+	for _, layer := range l {
+		for _, c := range layer.Components {
+			layerComponents[layer.Name] = append(layerComponents[layer.Name], c.Name)
+		}
 	}
 
-	for i, m := range workloadMatchers {
-		components[m.component] = ComponentRank{Layer: "workload", Component: m.component, Rank: 1000 + i*5}
+	components := make(map[string]ComponentRank)
+	lastRank := 0
+	for _, layer := range l.order() {
+		// The additional layers continue after the previous layer, the built-in
+		// ones keep their ranks unless pushed by the preceding layers.
+		base := lastRank + 5
+		if baseRank, ok := baseRanks[layer]; ok && (lastRank == 0 || baseRank > base) {
+			base = baseRank
+		}
+		for i, component := range layerComponents[layer] {
+			lastRank = base + i*5
+			components[component] = ComponentRank{Layer: layer, Component: component, Rank: lastRank}
+		}
 	}
 
 	ret := make([]ComponentRank, 0, len(components))
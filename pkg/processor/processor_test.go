@@ -0,0 +1,274 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+func alertsInGroups(groupIDs ...string) []prom.Alert {
+	alerts := make([]prom.Alert, 0, len(groupIDs))
+	for _, groupID := range groupIDs {
+		alerts = append(alerts, prom.Alert{Labels: map[string]string{"group_id": groupID}})
+	}
+	return alerts
+}
+
+// batchesGroupIDs processes the batches of alerts a step apart and returns
+// the group IDs assigned to the alerts, by alert name.
+//
+// The alerts are copied, as their labels get modified during processing,
+// so that the same batches can be processed by multiple collections.
+func batchesGroupIDs(gc *GroupsCollection, start time.Time, step time.Duration, batches ...[]prom.Alert) map[string]string {
+	groupIDs := make(map[string]string)
+	for i, batch := range batches {
+		alerts := make([]prom.Alert, 0, len(batch))
+		for _, a := range batch {
+			alerts = append(alerts, prom.Alert{Name: a.Name, Labels: maps.Clone(a.Labels)})
+		}
+		for _, a := range gc.ProcessAlertsBatch(alerts, start.Add(time.Duration(i)*step)) {
+			groupIDs[a.Name] = a.Labels["group_id"]
+		}
+	}
+	return groupIDs
+}
+
+// TestComputeGroupingStats tests the grouping quality statistics.
+func TestComputeGroupingStats(t *testing.T) {
+	// First iteration: 3 groups with 6 alerts, 2 of the groups with a single alert.
+	stats, groups := computeGroupingStats(
+		alertsInGroups("g1", "g1", "g1", "g1", "g2", "g3"), nil)
+
+	assert.Equal(t, 2.0, stats.avgIncidentSize)
+	assert.Equal(t, 2, stats.singleAlertIncidents)
+	// All groups were opened.
+	assert.Equal(t, 3, stats.churn)
+
+	// Second iteration: g3 closed, g4 opened.
+	stats, _ = computeGroupingStats(
+		alertsInGroups("g1", "g1", "g2", "g4"), groups)
+
+	assert.Equal(t, 4.0/3.0, stats.avgIncidentSize)
+	assert.Equal(t, 2, stats.singleAlertIncidents)
+	assert.Equal(t, 2, stats.churn)
+}
+
+// TestWeightedSeverity tests the weighted severity sums the highest severity
+// of each incident.
+func TestWeightedSeverity(t *testing.T) {
+	healthMaps := []ComponentHealthMap{
+		// Incident with critical severity.
+		{GroupId: "g1", Health: Warning},
+		{GroupId: "g1", Health: Critical},
+		// Incident with warning severity.
+		{GroupId: "g2", Health: Warning},
+		{GroupId: "g2", Health: Healthy},
+		// Incident with info severity.
+		{GroupId: "g3", Health: Healthy},
+		// Ungrouped health maps count separately.
+		{Health: Critical},
+		{Health: Warning},
+	}
+
+	assert.Equal(t, 6.0, weightedSeverity(healthMaps))
+	assert.Equal(t, 0.0, weightedSeverity(nil))
+}
+
+type fakeTimeLoader struct {
+	offset time.Duration
+	err    error
+}
+
+func (l fakeTimeLoader) LoadTime(context.Context) (time.Time, error) {
+	return time.Now().Add(l.offset), l.err
+}
+
+// TestCheckTimeSkew tests the time skew is measured against the time
+// reported by the source.
+func TestCheckTimeSkew(t *testing.T) {
+	// Prometheus clock is 2 minutes behind.
+	skew, err := checkTimeSkew(context.Background(), fakeTimeLoader{offset: -2 * time.Minute}, 30*time.Second)
+	assert.NoError(t, err)
+	assert.InDelta(t, (2 * time.Minute).Seconds(), skew.Seconds(), 1)
+
+	// Prometheus clock is ahead.
+	skew, err = checkTimeSkew(context.Background(), fakeTimeLoader{offset: time.Minute}, 30*time.Second)
+	assert.NoError(t, err)
+	assert.InDelta(t, -time.Minute.Seconds(), skew.Seconds(), 1)
+
+	_, err = checkTimeSkew(context.Background(), fakeTimeLoader{err: errors.New("unavailable")}, 30*time.Second)
+	assert.Error(t, err)
+}
+
+// TestDedupHealthMapsExcludedLabels tests the health maps differing only
+// in the excluded labels are merged.
+func TestDedupHealthMapsExcludedLabels(t *testing.T) {
+	healthMaps := func() []ComponentHealthMap {
+		return []ComponentHealthMap{
+			{Layer: "core", Component: "etcd", SrcType: Alert, GroupId: "g1", Health: Warning,
+				SrcLabels: map[string]string{"alertname": "etcdNoLeader", "pod": "etcd-1"}},
+			{Layer: "core", Component: "etcd", SrcType: Alert, GroupId: "g1", Health: Critical,
+				SrcLabels: map[string]string{"alertname": "etcdNoLeader", "pod": "etcd-2"}},
+		}
+	}
+
+	// By default, all labels are considered.
+	assert.Len(t, dedupHealthMaps(healthMaps(), nil), 2)
+
+	deduped := dedupHealthMaps(healthMaps(), []string{"src_pod"})
+	assert.Len(t, deduped, 1)
+	assert.Equal(t, Critical, deduped[0].Health)
+}
+
+// TestSummarizeLayers tests the incidents are counted per layer together
+// with the worst severity.
+func TestSummarizeLayers(t *testing.T) {
+	healthMaps := []ComponentHealthMap{
+		{Layer: "core", Component: "etcd", GroupId: "g1", Health: Warning},
+		{Layer: "core", Component: "etcd", GroupId: "g1", Health: Critical},
+		{Layer: "core", Component: "network", GroupId: "g2", Health: Warning},
+		{Layer: "workload", Component: "app", GroupId: "g2", Health: Healthy},
+		{Layer: "Others", Component: "Others", Health: Warning},
+	}
+
+	assert.Equal(t, []LayerSummary{
+		{Layer: "compute"},
+		{Layer: "core", Incidents: 2, Severity: "critical"},
+		{Layer: "workload", Incidents: 1, Severity: "info"},
+		{Layer: "Others", Incidents: 1, Severity: "warning"},
+	}, SummarizeLayers(healthMaps))
+}
+
+// TestProcessorPruneInterval tests the groups are pruned on their own
+// schedule rather than when processing the alerts.
+func TestProcessorPruneInterval(t *testing.T) {
+	now := time.Now()
+	oldGroup := func() *GroupMatcher {
+		// A fuzzy group not modified for longer than the fuzzy matching window.
+		modified := model.TimeFromUnixNano(now.Add(-48 * time.Hour).UnixNano())
+		return &GroupMatcher{GroupID: "old", RootGroupID: "old", Start: modified, Modified: modified,
+			End: modified, Distance: 1, Matchers: []labelsSubsetMatcher{{Labels: map[string]string{"namespace": "ns1"}}}}
+	}
+	p := &processor{
+		metrics:          Metrics{Groups: prom.NewMetricSet("test_groups", "Test groups.")},
+		pruneInterval:    10 * time.Millisecond,
+		groupsCollection: &GroupsCollection{Groups: []*GroupMatcher{oldGroup()}},
+	}
+
+	// Processing the alerts doesn't prune the groups.
+	p.assignAlertsToGroups(nil, now)
+	assert.Len(t, p.groupsCollection.Groups, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.runPruning(ctx)
+
+	assert.Eventually(t, func() bool {
+		p.groupsMu.Lock()
+		defer p.groupsMu.Unlock()
+		return len(p.groupsCollection.Groups) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestGroupsCount tests the groups are counted by the distance of their matchers.
+func TestGroupsCount(t *testing.T) {
+	groups := []*GroupMatcher{
+		{RootGroupID: "g1", Distance: 0},
+		{RootGroupID: "g1", Distance: 2},
+		{RootGroupID: "g1", Distance: math.Inf(1)},
+		{RootGroupID: "g2", Distance: 1},
+		{RootGroupID: "g2", Distance: 2},
+		{RootGroupID: "g3", Distance: 0},
+	}
+
+	assert.Equal(t, []prom.Metric{
+		{Labels: map[string]string{"distance": "all"}, Value: 3},
+		{Labels: map[string]string{"distance": "direct"}, Value: 2},
+		{Labels: map[string]string{"distance": "fuzzy"}, Value: 2},
+		{Labels: map[string]string{"distance": "time"}, Value: 1},
+	}, groupsCount(groups))
+}
+
+// TestLayersComponentRanks tests the alerts are mapped to the components of
+// an additional layer ranked between the core and workload layers.
+func TestLayersComponentRanks(t *testing.T) {
+	layers := Layers{{
+		Name:  "platform-addons",
+		After: "core",
+		Components: []LayerComponent{
+			{Name: "addons", Namespaces: []string{"openshift-addons"}},
+			{Name: "gitops", Namespaces: []string{"openshift-gitops"}},
+		},
+	}}
+	assert.NoError(t, layers.validate())
+	assert.Equal(t, []string{"compute", "core", "platform-addons", "workload"}, layers.order())
+
+	healthMaps := layers.MapAlerts([]prom.Alert{
+		{Labels: map[string]string{"alertname": "AddonDown", "namespace": "openshift-addons", "severity": "warning"}},
+	})
+	assert.Equal(t, "platform-addons", healthMaps[0].Layer)
+	assert.Equal(t, "addons", healthMaps[0].Component)
+
+	ranks := make(map[string][]int)
+	for _, r := range layers.BuildComponentRanks() {
+		ranks[r.Layer] = append(ranks[r.Layer], r.Rank)
+	}
+	assert.Len(t, ranks["platform-addons"], 2)
+	for _, rank := range ranks["platform-addons"] {
+		assert.Greater(t, rank, slices.Max(ranks["core"]))
+		assert.Less(t, rank, slices.Min(ranks["workload"]))
+	}
+
+	// The built-in layers keep their ranks.
+	assert.Equal(t, 1, slices.Min(ranks["compute"]))
+	assert.Equal(t, 10, slices.Min(ranks["core"]))
+	assert.Equal(t, 1000, slices.Min(ranks["workload"]))
+	assert.Error(t, Layers{{Name: "addons", After: "unknown",
+		Components: []LayerComponent{{Name: "a", Namespaces: []string{"ns"}}}}}.validate())
+}
+
+// TestComponentSLAsCheckSLAs tests the incidents lasting longer than the
+// tightest target of their components are flagged.
+func TestComponentSLAsCheckSLAs(t *testing.T) {
+	start := model.TimeFromUnixNano(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	incidents := []Incident{
+		{GroupID: "g1", Start: start, End: start.Add(90 * time.Minute),
+			Components: []IncidentComponent{{Layer: "core", Component: "etcd"}, {Layer: "core", Component: "network"}}},
+		{GroupID: "g2", Start: start, End: start.Add(90 * time.Minute),
+			Components: []IncidentComponent{{Layer: "core", Component: "network"}}},
+		{GroupID: "g3", Start: start, End: start.Add(90 * time.Minute),
+			Components: []IncidentComponent{{Layer: "workload", Component: "app"}}},
+	}
+	ComponentSLAs{"etcd": time.Hour, "network": 4 * time.Hour}.CheckSLAs(incidents)
+
+	// The etcd target applies to the incident touching both components.
+	assert.Equal(t, time.Hour, incidents[0].SLATarget)
+	assert.True(t, incidents[0].SLABreached)
+	assert.Equal(t, 4*time.Hour, incidents[1].SLATarget)
+	assert.False(t, incidents[1].SLABreached)
+	// Components without a target are never breached.
+	assert.Zero(t, incidents[2].SLATarget)
+	assert.False(t, incidents[2].SLABreached)
+}
+
+// TestUnmappedAlerts tests the alerts not mapped to any component are counted.
+func TestUnmappedAlerts(t *testing.T) {
+	healthMaps := MapAlerts([]prom.Alert{
+		{Name: "CustomAlert", Labels: map[string]string{"alertname": "CustomAlert", "namespace": "unknown", "pod": "a"}},
+		{Name: "CustomAlert", Labels: map[string]string{"alertname": "CustomAlert", "namespace": "unknown", "pod": "b"}},
+		{Name: "etcdNoLeader", Labels: map[string]string{"alertname": "etcdNoLeader", "namespace": "openshift-etcd"}},
+	})
+
+	assert.Equal(t, []prom.Metric{
+		{Labels: map[string]string{"alertname": "CustomAlert", "namespace": "unknown"}, Value: 2},
+	}, unmappedAlerts(healthMaps))
+}
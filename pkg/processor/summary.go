@@ -0,0 +1,130 @@
+package processor
+
+// This file contains logic for summarizing grouped alerts into incidents.
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+// IncidentComponent identifies a component affected by an incident.
+type IncidentComponent struct {
+	Layer     string
+	Component string
+}
+
+// Incident summarizes the alerts sharing the same group ID.
+type Incident struct {
+	GroupID    string
+	Start      model.Time
+	End        model.Time
+	Severity   HealthValue
+	Components []IncidentComponent
+	Alerts     []string
+
+	// SLATarget is the tightest resolution target of the incident
+	// components, zero when none of them has a target.
+	SLATarget time.Duration
+	// SLABreached is set when the incident lasts longer than its target.
+	SLABreached bool
+}
+
+// ComponentSLAs are the targets of the time to resolve the incidents
+// affecting the components, by component name.
+//
+// Only the time to resolve is checked: the incidents don't carry any
+// acknowledgement state, so time-to-acknowledge targets are not supported.
+type ComponentSLAs map[string]time.Duration
+
+// CheckSLAs sets the SLA target of the incidents and flags the incidents
+// lasting longer than their target.
+func (s ComponentSLAs) CheckSLAs(incidents []Incident) {
+	for i, incident := range incidents {
+		var target time.Duration
+		for _, c := range incident.Components {
+			if t, ok := s[c.Component]; ok && (target == 0 || t < target) {
+				target = t
+			}
+		}
+		incidents[i].SLATarget = target
+		incidents[i].SLABreached = target > 0 && incident.End.Sub(incident.Start) > target
+	}
+}
+
+// GroupIncidents groups the alerts history into incidents, the same way
+// the processor groups the historical alerts when starting.
+func GroupIncidents(alertsRange prom.RangeVector, cfg GroupingConfig) []Incident {
+	return Layers(nil).GroupIncidents(alertsRange, cfg)
+}
+
+// GroupIncidents groups the alerts history into incidents, mapping the
+// alerts to the components of the additional layers.
+func (l Layers) GroupIncidents(alertsRange prom.RangeVector, cfg GroupingConfig) []Incident {
+	gc := &GroupsCollection{Config: cfg, layers: l}
+	var groupedIntervals []GroupedInterval
+	for _, change := range metricsChanges(alertsRange, cfg.resolutionGrace) {
+		groupedIntervals = append(groupedIntervals, gc.ProcessIntervalsBatch(change.Intervals)...)
+	}
+	return l.BuildIncidents(groupedIntervals)
+}
+
+// BuildIncidents summarizes the grouped intervals into incidents.
+//
+// The alerts are mapped to components the same way as for the health map
+// metrics. The incidents are sorted by their start time.
+func BuildIncidents(groupedIntervals []GroupedInterval) []Incident {
+	return Layers(nil).BuildIncidents(groupedIntervals)
+}
+
+// BuildIncidents summarizes the grouped intervals into incidents, including
+// the components of the additional layers.
+func (l Layers) BuildIncidents(groupedIntervals []GroupedInterval) []Incident {
+	incidentsMap := make(map[string]*Incident)
+	for _, gi := range groupedIntervals {
+		groupID := gi.GroupMatcher.RootGroupID
+		healthMap := getAlertHealthMap(prom.Alert{Labels: gi.Metric.MLabels()}, l)
+
+		incident, ok := incidentsMap[groupID]
+		if !ok {
+			incident = &Incident{GroupID: groupID, Start: gi.Start, End: gi.End}
+			incidentsMap[groupID] = incident
+		}
+		incident.Start = min(incident.Start, gi.Start)
+		incident.End = max(incident.End, gi.End)
+		incident.Severity = max(incident.Severity, healthMap.Health)
+
+		component := IncidentComponent{Layer: healthMap.Layer, Component: healthMap.Component}
+		if !slices.Contains(incident.Components, component) {
+			incident.Components = append(incident.Components, component)
+		}
+		alertname := gi.Metric.MLabels()["alertname"]
+		if !slices.Contains(incident.Alerts, alertname) {
+			incident.Alerts = append(incident.Alerts, alertname)
+		}
+	}
+
+	incidents := make([]Incident, 0, len(incidentsMap))
+	for _, incident := range incidentsMap {
+		sort.Slice(incident.Components, func(i, j int) bool {
+			a, b := incident.Components[i], incident.Components[j]
+			if a.Layer != b.Layer {
+				return a.Layer < b.Layer
+			}
+			return a.Component < b.Component
+		})
+		slices.Sort(incident.Alerts)
+		incidents = append(incidents, *incident)
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		if incidents[i].Start != incidents[j].Start {
+			return incidents[i].Start.Before(incidents[j].Start)
+		}
+		return incidents[i].GroupID < incidents[j].GroupID
+	})
+	return incidents
+}
@@ -0,0 +1,151 @@
+package processor
+
+// This file contains logic for suppressing alerts before they are processed.
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+// SuppressionRule drops the matching alerts before they are grouped into
+// incidents and mapped to components.
+type SuppressionRule struct {
+	// Name identifies the rule in the metrics.
+	Name string `json:"name"`
+
+	// Matchers are regular expressions the alert labels have to match.
+	// The expressions are anchored. All of the matchers have to match.
+	Matchers map[string]string `json:"matchers"`
+
+	// Start and End restrict the rule to a time of day window in UTC,
+	// in the 15:04 format. The window can span midnight. When not set,
+	// the rule applies at any time.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+
+	matchers map[string]*regexp.Regexp
+	start    time.Duration
+	end      time.Duration
+}
+
+// compile validates the rule and prepares it for matching.
+func (r *SuppressionRule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("missing rule name")
+	}
+	if len(r.Matchers) == 0 {
+		return fmt.Errorf("rule %q: no matchers", r.Name)
+	}
+	if (r.Start == "") != (r.End == "") {
+		return fmt.Errorf("rule %q: both start and end have to be set", r.Name)
+	}
+
+	r.matchers = make(map[string]*regexp.Regexp, len(r.Matchers))
+	for k, v := range r.Matchers {
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			return fmt.Errorf("rule %q: invalid matcher for label %q: %w", r.Name, k, err)
+		}
+		r.matchers[k] = re
+	}
+
+	if r.Start != "" {
+		var err error
+		if r.start, err = parseTimeOfDay(r.Start); err != nil {
+			return fmt.Errorf("rule %q: invalid start: %w", r.Name, err)
+		}
+		if r.end, err = parseTimeOfDay(r.End); err != nil {
+			return fmt.Errorf("rule %q: invalid end: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// active returns true if the rule applies at the given time.
+func (r *SuppressionRule) active(t time.Time) bool {
+	if r.Start == "" {
+		return true
+	}
+	t = t.UTC()
+	tod := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+	if r.start <= r.end {
+		return tod >= r.start && tod < r.end
+	}
+	// The window spans midnight.
+	return tod >= r.start || tod < r.end
+}
+
+// matches returns true if all the matchers match the labels.
+func (r *SuppressionRule) matches(labels map[string]string) bool {
+	for k, re := range r.matchers {
+		if !re.MatchString(labels[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadSuppressionRules reads the suppression rules from a YAML or JSON file.
+func LoadSuppressionRules(path string) ([]SuppressionRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []SuppressionRule
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid suppression rules %s: %w", path, err)
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// suppressAlerts drops the alerts matching any of the rules active at time t.
+//
+// It returns the remaining alerts and the number of suppressed alerts per rule.
+// An alert matching multiple rules is counted for the first one.
+func suppressAlerts(rules []SuppressionRule, alerts []prom.Alert, t time.Time) (
+	[]prom.Alert, map[string]int) {
+	suppressed := make(map[string]int, len(rules))
+	if len(rules) == 0 {
+		return alerts, suppressed
+	}
+
+	active := make([]*SuppressionRule, 0, len(rules))
+	for i := range rules {
+		suppressed[rules[i].Name] = 0
+		if rules[i].active(t) {
+			active = append(active, &rules[i])
+		}
+	}
+
+	ret := make([]prom.Alert, 0, len(alerts))
+alerts:
+	for _, a := range alerts {
+		for _, r := range active {
+			if r.matches(a.Labels) {
+				suppressed[r.Name]++
+				continue alerts
+			}
+		}
+		ret = append(ret, a)
+	}
+	return ret, suppressed
+}
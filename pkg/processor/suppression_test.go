@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+func TestSuppressAlerts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- name: maintenance
+  matchers:
+    namespace: openshift-.*-maintenance
+  start: "22:00"
+  end: "02:00"
+- name: noisy
+  matchers:
+    alertname: NoisyAlert
+`), 0o600))
+
+	rules, err := LoadSuppressionRules(path)
+	require.NoError(t, err)
+
+	alerts := []prom.Alert{
+		{Name: "Alert1", Labels: map[string]string{"alertname": "Alert1", "namespace": "openshift-db-maintenance"}},
+		{Name: "Alert2", Labels: map[string]string{"alertname": "Alert2", "namespace": "openshift-db"}},
+		{Name: "NoisyAlert", Labels: map[string]string{"alertname": "NoisyAlert", "namespace": "openshift-db"}},
+	}
+
+	// Inside the maintenance window, spanning midnight.
+	for _, ts := range []string{"2024-07-01T23:30:00Z", "2024-07-02T01:00:00Z"} {
+		now, err := time.Parse(time.RFC3339, ts)
+		require.NoError(t, err)
+
+		remaining, suppressed := suppressAlerts(rules, alerts, now)
+		require.Len(t, remaining, 1, ts)
+		assert.Equal(t, "Alert2", remaining[0].Name)
+		assert.Equal(t, map[string]int{"maintenance": 1, "noisy": 1}, suppressed)
+	}
+
+	// Outside of the maintenance window.
+	now, err := time.Parse(time.RFC3339, "2024-07-01T12:00:00Z")
+	require.NoError(t, err)
+	remaining, suppressed := suppressAlerts(rules, alerts, now)
+	require.Len(t, remaining, 2)
+	assert.Equal(t, "Alert1", remaining[0].Name)
+	assert.Equal(t, "Alert2", remaining[1].Name)
+	assert.Equal(t, map[string]int{"maintenance": 0, "noisy": 1}, suppressed)
+}
+
+func TestSuppressionRuleInvalid(t *testing.T) {
+	for name, rule := range map[string]SuppressionRule{
+		"missing name":     {Matchers: map[string]string{"namespace": "foo"}},
+		"missing matchers": {Name: "rule"},
+		"invalid regexp":   {Name: "rule", Matchers: map[string]string{"namespace": "("}},
+		"missing end":      {Name: "rule", Matchers: map[string]string{"namespace": "foo"}, Start: "10:00"},
+		"invalid start":    {Name: "rule", Matchers: map[string]string{"namespace": "foo"}, Start: "25:00", End: "10:00"},
+	} {
+		assert.Error(t, rule.compile(), name)
+	}
+}
@@ -39,14 +39,32 @@ const (
 	SrcLabelPrefix = "src_"
 )
 
+// String returns the name of the health value, as used for alert severities.
+func (h HealthValue) String() string {
+	switch h {
+	case Healthy:
+		return "info"
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	default:
+		return "none"
+	}
+}
+
 // hashLabelValues returns a hash of the labels of the component.
 //
 // This is used to uniquely identify the component when deduplicating.
-func (c ComponentHealthMap) hashLabelValues() uint64 {
+// The excluded labels are not part of the hash.
+func (c ComponentHealthMap) hashLabelValues(excluded ...string) uint64 {
 	h := fnv.New64a()
 	labels := c.Labels()
 	keys := make([]string, 0, len(labels))
 	for k := range labels {
+		if slices.Contains(excluded, k) {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	slices.Sort(keys)
@@ -252,12 +270,21 @@ type componentMatcherFn func(labels map[string]string) (layer, comp string, keys
 
 func evalMatcherFns(fns []componentMatcherFn, labels map[string]string) (
 	layer, comp string, labelsSubset map[string]string) {
+	layer, comp, keys := matchComponent(fns, labels)
+	return layer, comp, getLabelsSubset(labels, keys...)
+}
+
+// matchComponent returns the layer and component of the first matching
+// function together with the keys used for the matching, falling back
+// to the Others component.
+func matchComponent(fns []componentMatcherFn, labels map[string]string) (
+	layer, comp string, keys []string) {
 	for _, fn := range fns {
 		if layer, comp, keys := fn(labels); layer != "" {
-			return layer, comp, getLabelsSubset(labels, keys...)
+			return layer, comp, keys
 		}
 	}
-	return "Others", "Others", getLabelsSubset(labels)
+	return "Others", "Others", nil
 }
 
 // getLabelsSubset returns a subset of the labels with given keys.
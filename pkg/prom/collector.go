@@ -1,7 +1,8 @@
 package prom
 
 import (
-	"sync"
+	"maps"
+	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 	prom "github.com/prometheus/client_golang/prometheus"
@@ -12,11 +13,15 @@ type Metric struct {
 	Value  float64
 }
 
+// metricSet keeps the metrics as an immutable snapshot.
+//
+// Every Update builds a complete new snapshot and swaps it in atomically,
+// so Collect always sees either the previous or the new set, never a mix
+// of the two, and scrapes don't block the updates.
 type metricSet struct {
-	mtx     sync.RWMutex
-	metrics []Metric
-	name    string
-	help    string
+	snapshot atomic.Pointer[[]Metric]
+	name     string
+	help     string
 }
 
 // MetricSet is an expasion of prometheus.Collector interface that allows batch
@@ -24,6 +29,8 @@ type metricSet struct {
 // exposed to Prometheus via different metric.
 type MetricSet interface {
 	prometheus.Collector
+	// Update atomically replaces the whole set of metrics. The provided
+	// metrics are copied, so the caller is free to reuse them afterwards.
 	Update(metrics []Metric)
 }
 
@@ -32,21 +39,33 @@ func NewMetricSet(name, help string) *metricSet {
 }
 
 func (m *metricSet) Update(metrics []Metric) {
-	m.mtx.Lock()
-	defer m.mtx.Unlock()
-	m.metrics = metrics
+	snapshot := make([]Metric, len(metrics))
+	for i, metric := range metrics {
+		snapshot[i] = Metric{
+			Labels: maps.Clone(metric.Labels),
+			Value:  metric.Value,
+		}
+	}
+	m.snapshot.Store(&snapshot)
 }
 
 func (m *metricSet) Reset() {
-	m.mtx.Lock()
-	defer m.mtx.Unlock()
-	m.metrics = nil
+	m.snapshot.Store(nil)
+}
+
+// Snapshot returns the current set of metrics.
+//
+// The returned slice is shared with other readers and must not be modified.
+func (m *metricSet) Snapshot() []Metric {
+	snapshot := m.snapshot.Load()
+	if snapshot == nil {
+		return nil
+	}
+	return *snapshot
 }
 
 func (m *metricSet) Collect(ch chan<- prom.Metric) {
-	m.mtx.RLock()
-	defer m.mtx.RUnlock()
-	for _, metric := range m.metrics {
+	for _, metric := range m.Snapshot() {
 		labels := make([]string, 0, len(metric.Labels))
 		values := make([]string, 0, len(metric.Labels))
 		for k, v := range metric.Labels {
@@ -0,0 +1,77 @@
+package prom
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetricSetConcurrentUpdateCollect updates the metric set while it's being
+// scraped and checks each scrape sees a complete, consistent set.
+//
+// Run with -race to detect unsynchronized access.
+func TestMetricSetConcurrentUpdateCollect(t *testing.T) {
+	const setSize = 20
+	const generations = 200
+
+	ms := NewMetricSet("test_metric", "Test metric.")
+	ms.Update(buildGeneration(0, setSize))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for gen := 1; gen <= generations; gen++ {
+			metrics := buildGeneration(gen, setSize)
+			ms.Update(metrics)
+			// Mutating the input after the update must not leak into the snapshot.
+			metrics[0].Labels["idx"] = "modified"
+			metrics[0].Value = -1
+		}
+	}()
+
+	for i := 0; i < generations; i++ {
+		values := collectValues(t, ms)
+		require.Len(t, values, setSize)
+		for _, v := range values {
+			assert.Equal(t, values[0], v, "scrape observed a mix of generations")
+		}
+	}
+	wg.Wait()
+
+	values := collectValues(t, ms)
+	require.Len(t, values, setSize)
+	assert.Equal(t, float64(generations), values[0])
+}
+
+func buildGeneration(gen, size int) []Metric {
+	metrics := make([]Metric, size)
+	for i := range metrics {
+		metrics[i] = Metric{
+			Labels: prometheus.Labels{"idx": fmt.Sprint(i)},
+			Value:  float64(gen),
+		}
+	}
+	return metrics
+}
+
+func collectValues(t *testing.T, ms *metricSet) []float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		ms.Collect(ch)
+		close(ch)
+	}()
+
+	var values []float64
+	for m := range ch {
+		var pb dto.Metric
+		require.NoError(t, m.Write(&pb))
+		values = append(values, pb.GetGauge().GetValue())
+	}
+	return values
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -18,63 +19,177 @@ import (
 	"github.com/prometheus/common/model"
 )
 
+const (
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+)
+
 type loader struct {
 	api v1.API
+
+	// queryTimeout limits each API call, unless overridden by the caller.
+	queryTimeout time.Duration
 }
 
 type Loader struct {
 	*loader
 }
 
-func NewLoader(prometheusURL string) (*Loader, error) {
+// LoaderConfig holds the connection settings for the Prometheus API.
+//
+// The zero value means running in-cluster: the service account token and
+// the service CA are used for https:// URLs. When any of the fields is set,
+// only the explicitly configured values are used, without falling back to
+// the in-cluster files. This allows connecting to a remote Prometheus/Thanos.
+type LoaderConfig struct {
+	// Token is the bearer token used for authentication.
+	Token string
+
+	// TokenFile is the path to a file with the bearer token.
+	// Ignored if Token is set.
+	TokenFile string
+
+	// CAFile is the path to the CA bundle used to verify the server certificate.
+	// If empty for a remote Prometheus, the system roots are used.
+	CAFile string
+
+	// QueryTimeout is the default timeout of each query, on top of the
+	// deadline of the caller's context (0 means no timeout).
+	QueryTimeout time.Duration
+}
+
+func (c LoaderConfig) inCluster() bool {
+	return c.Token == "" && c.TokenFile == "" && c.CAFile == ""
+}
+
+type queryTimeoutKey struct{}
+
+// WithQueryTimeout overrides the default query timeout of the loader for
+// the calls made with the returned context (0 disables the timeout).
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutKey{}, timeout)
+}
+
+// queryContext limits the context of a single API call by the query timeout.
+func (c *loader) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := c.queryTimeout
+	if override, ok := ctx.Value(queryTimeoutKey{}).(time.Duration); ok {
+		timeout = override
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func NewLoader(prometheusURL string, cfg LoaderConfig) (*Loader, error) {
 	if !regexp.MustCompile(`^(http|https)://`).MatchString(prometheusURL) {
 		return nil, errors.New("invalid URL: must start with https:// or http://")
 	}
 
+	if cfg.inCluster() && strings.HasPrefix(prometheusURL, "https://") {
+		cfg = LoaderConfig{
+			TokenFile:    serviceAccountTokenFile,
+			CAFile:       serviceAccountCAFile,
+			QueryTimeout: cfg.QueryTimeout,
+		}
+	}
+
 	api_config := api.Config{
 		Address: prometheusURL,
 	}
 
 	use_tls := strings.HasPrefix(prometheusURL, "https://")
-	if use_tls {
-		token, err := os.ReadFile(`/var/run/secrets/kubernetes.io/serviceaccount/token`)
+	if !use_tls {
+		slog.Warn("Connecting to Prometheus without TLS")
+	}
+
+	roundTripper, err := cfg.roundTripper()
+	if err != nil {
+		return nil, err
+	}
+	if roundTripper != nil {
+		api_config.RoundTripper = roundTripper
+	}
+
+	promClient, err := api.NewClient(api_config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Loader{
+		&loader{
+			api:          v1.NewAPI(promClient),
+			queryTimeout: cfg.QueryTimeout,
+		},
+	}, nil
+}
+
+// roundTripper builds the round tripper for the configured CA and token.
+//
+// It returns nil when the default round tripper can be used.
+func (c LoaderConfig) roundTripper() (http.RoundTripper, error) {
+	if c.inCluster() {
+		return nil, nil
+	}
+
+	rt := api.DefaultRoundTripper.(*http.Transport).Clone()
+
+	if c.CAFile != "" {
+		pemData, err := os.ReadFile(c.CAFile)
 		if err != nil {
-			slog.Error("Failed to read the service account token", "err", err)
+			slog.Error("Failed to read the CA certificate", "err", err)
 			return nil, err
 		}
 
 		certs := x509.NewCertPool()
+		if !certs.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no valid certificates found in %s", c.CAFile)
+		}
+		rt.TLSClientConfig = &tls.Config{RootCAs: certs}
+	}
 
-		pemData, err := os.ReadFile(`/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt`)
+	token := c.Token
+	if token == "" && c.TokenFile != "" {
+		data, err := os.ReadFile(c.TokenFile)
 		if err != nil {
-			slog.Error("Failed to read the CA certificate", "err", err)
+			slog.Error("Failed to read the token", "err", err)
 			return nil, err
 		}
-		certs.AppendCertsFromPEM(pemData)
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return rt, nil
+	}
 
-		defaultRt := api.DefaultRoundTripper.(*http.Transport)
-		defaultRt.TLSClientConfig = &tls.Config{RootCAs: certs}
+	return prom_config.NewAuthorizationCredentialsRoundTripper(
+		"Bearer", prom_config.NewInlineSecret(token), rt), nil
+}
 
-		api_config.RoundTripper = prom_config.NewAuthorizationCredentialsRoundTripper(
-			"Bearer", prom_config.NewInlineSecret(string(token)), defaultRt)
-	} else {
-		slog.Warn("Connecting to Prometheus without TLS")
+// alignRange snaps the range to the multiples of the step.
+//
+// Prometheus evaluates the range queries at the start and then every step,
+// so the samples are placed at the same timestamps regardless of when the
+// range is queried, and the last sample is at the end of the range.
+func alignRange(start, end time.Time, step time.Duration) (time.Time, time.Time) {
+	stepMs := step.Milliseconds()
+	if stepMs <= 0 {
+		return start, end
 	}
-
-	promClient, err := api.NewClient(api_config)
-	if err != nil {
-		return nil, err
+	align := func(t time.Time) time.Time {
+		ms := t.UnixMilli()
+		return time.UnixMilli(ms - ms%stepMs)
 	}
-
-	return &Loader{
-		&loader{
-			api: v1.NewAPI(promClient),
-		},
-	}, nil
+	return align(start), align(end)
 }
 
+// firingAlertsQuery selects the currently firing alerts.
+var firingAlertsQuery = BuildSelector("ALERTS", map[string]string{"alertstate": "firing"})
+
 func (c *loader) LoadAlerts(ctx context.Context, t time.Time) ([]Alert, error) {
-	result, _, err := c.api.Query(ctx, `ALERTS{alertstate="firing"}`, t)
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	result, _, err := c.api.Query(ctx, firingAlertsQuery, t)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +211,10 @@ func (c *loader) LoadAlerts(ctx context.Context, t time.Time) ([]Alert, error) {
 }
 
 func (c *loader) LoadAlertsRange(ctx context.Context, start, end time.Time, step time.Duration) (RangeVector, error) {
-	result, _, err := c.api.QueryRange(ctx, `ALERTS{alertstate="firing"}`, v1.Range{
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	start, end = alignRange(start, end, step)
+	result, _, err := c.api.QueryRange(ctx, firingAlertsQuery, v1.Range{
 		Start: start,
 		End:   end,
 		Step:  step,
@@ -124,7 +242,50 @@ func (c *loader) LoadAlertsRange(ctx context.Context, start, end time.Time, step
 	return ret, nil
 }
 
+// LoadVector evaluates the query at the given time.
+func (c *loader) LoadVector(ctx context.Context, query string, t time.Time) ([]Metric, error) {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	result, _, err := c.api.Query(ctx, query, t)
+	if err != nil {
+		return nil, err
+	}
+	vect, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type for %s: %s", query, result.Type())
+	}
+	ret := make([]Metric, len(vect))
+	for i, sample := range vect {
+		labels := make(map[string]string, len(sample.Metric))
+		for k, v := range sample.Metric {
+			labels[string(k)] = string(v)
+		}
+		ret[i] = Metric{Labels: labels, Value: float64(sample.Value)}
+	}
+	return ret, nil
+}
+
+// LoadTime returns the current time as reported by Prometheus.
+func (c *loader) LoadTime(ctx context.Context) (time.Time, error) {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	// Without the evaluation time, Prometheus evaluates the query at its own
+	// current time rather than the local one.
+	result, _, err := c.api.Query(ctx, "time()", time.Time{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	scalar, ok := result.(*model.Scalar)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected result type for time(): %s", result.Type())
+	}
+	return time.UnixMilli(int64(float64(scalar.Value) * 1000)), nil
+}
+
 func (c *loader) LoadVectorRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (RangeVector, error) {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	start, end = alignRange(start, end, step)
 	result, _, err := c.api.QueryRange(ctx, query, v1.Range{
 		Start: start,
 		End:   end,
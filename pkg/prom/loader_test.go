@@ -0,0 +1,179 @@
+package prom
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoaderRemotePrometheus connects the loader to a remote Prometheus
+// using explicitly configured CA and token, without any in-cluster
+// service account files present.
+func TestLoaderRemotePrometheus(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer remote-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"__name__":"ALERTS","alertname":"Alert1","alertstate":"firing"},"value":[1720000000,"1"]}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	tokenFile := filepath.Join(dir, "token")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+	require.NoError(t, os.WriteFile(tokenFile, []byte("remote-token\n"), 0o600))
+
+	for name, cfg := range map[string]LoaderConfig{
+		"token":      {Token: "remote-token", CAFile: caFile},
+		"token file": {TokenFile: tokenFile, CAFile: caFile},
+	} {
+		t.Run(name, func(t *testing.T) {
+			loader, err := NewLoader(srv.URL, cfg)
+			require.NoError(t, err)
+
+			alerts, err := loader.LoadAlerts(context.Background(), time.Now())
+			require.NoError(t, err)
+			require.Len(t, alerts, 1)
+			assert.Equal(t, "Alert1", alerts[0].Name)
+		})
+	}
+}
+
+func TestLoaderInvalidCAFile(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+
+	_, err := NewLoader("https://thanos.example.com", LoaderConfig{CAFile: caFile})
+	assert.Error(t, err)
+}
+
+func TestLoaderLoadTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The query is evaluated at the Prometheus clock.
+		assert.NoError(t, r.ParseForm())
+		assert.NotContains(t, r.Form, "time")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1720000000.5,"1720000000.5"]}}`))
+	}))
+	defer srv.Close()
+
+	loader, err := NewLoader(srv.URL, LoaderConfig{})
+	require.NoError(t, err)
+
+	promTime, err := loader.LoadTime(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, time.UnixMilli(1720000000500), promTime)
+}
+
+// TestLoaderQueryTimeout tests the queries are canceled after the configured
+// timeout, unless overridden for the call.
+func TestLoaderQueryTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A slow Prometheus, answering only after the client gives up.
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	loader, err := NewLoader(srv.URL, LoaderConfig{QueryTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	now := time.Now()
+	_, err = loader.LoadVectorRange(context.Background(), "up", now.Add(-time.Hour), now, time.Minute)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(now), 500*time.Millisecond)
+
+	// The timeout is disabled for the call.
+	ctx := WithQueryTimeout(context.Background(), 0)
+	_, err = loader.LoadVectorRange(ctx, "up", now.Add(-time.Hour), now, time.Minute)
+	assert.NoError(t, err)
+}
+
+func TestLoaderLoadVector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"__name__":"cluster_operator_conditions","name":"etcd","condition":"Degraded"},"value":[1720000000,"1"]}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	loader, err := NewLoader(srv.URL, LoaderConfig{})
+	require.NoError(t, err)
+
+	metrics, err := loader.LoadVector(context.Background(), "cluster_operator_conditions", time.Now())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "etcd", metrics[0].Labels["name"])
+	assert.Equal(t, 1.0, metrics[0].Value)
+}
+
+// TestLoaderRangeAlignment tests the samples of the ranges queried at
+// different times within a step are placed at the same timestamps.
+func TestLoaderRangeAlignment(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		start, err := strconv.ParseFloat(r.Form.Get("start"), 64)
+		assert.NoError(t, err)
+		end, err := strconv.ParseFloat(r.Form.Get("end"), 64)
+		assert.NoError(t, err)
+		step, err := strconv.ParseFloat(r.Form.Get("step"), 64)
+		assert.NoError(t, err)
+
+		// Evaluate the query at the start and then every step, as Prometheus does.
+		var values []string
+		for ts := start; ts <= end; ts += step {
+			values = append(values, fmt.Sprintf(`[%s,"1"]`, strconv.FormatFloat(ts, 'f', -1, 64)))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"alertname":"Alert1","alertstate":"firing"},"values":[%s]}]}}`, strings.Join(values, ","))
+	}))
+	defer srv.Close()
+
+	loader, err := NewLoader(srv.URL, LoaderConfig{})
+	require.NoError(t, err)
+
+	end := time.Date(2024, 7, 1, 1, 0, 0, 0, time.UTC)
+	var timestamps [][]model.Time
+	// Windows not aligned to the step.
+	for _, offset := range []time.Duration{0, 17 * time.Second, 59 * time.Second} {
+		rv, err := loader.LoadAlertsRange(context.Background(),
+			end.Add(-time.Hour).Add(offset), end.Add(offset), time.Minute)
+		require.NoError(t, err)
+		require.Len(t, rv, 1)
+
+		var ts []model.Time
+		for _, s := range rv[0].Samples {
+			ts = append(ts, s.Timestamp)
+		}
+		timestamps = append(timestamps, ts)
+	}
+
+	assert.Len(t, timestamps[0], 61)
+	assert.Equal(t, model.TimeFromUnixNano(end.UnixNano()), timestamps[0][60])
+	assert.Equal(t, timestamps[0], timestamps[1])
+	assert.Equal(t, timestamps[0], timestamps[2])
+}
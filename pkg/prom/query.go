@@ -0,0 +1,47 @@
+package prom
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EscapeLabelValue quotes the value to be used in a PromQL label matcher.
+//
+// The value is returned as a double-quoted string literal, with quotes,
+// backslashes and control characters escaped.
+func EscapeLabelValue(value string) string {
+	return strconv.Quote(value)
+}
+
+// BuildSelector builds a PromQL series selector for the metric, matching
+// the labels by equality.
+//
+// The matchers are sorted by the label names for a stable output.
+func BuildSelector(metric string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	matchers := make([]string, 0, len(keys))
+	for _, k := range keys {
+		matchers = append(matchers, k+"="+EscapeLabelValue(labels[k]))
+	}
+	return metric + "{" + strings.Join(matchers, ",") + "}"
+}
+
+// BuildSelectorAny builds a PromQL series selector for the metric, matching
+// the label against any of the values.
+//
+// The values are matched literally, with the regular expression
+// metacharacters escaped.
+func BuildSelectorAny(metric, label string, values []string) string {
+	patterns := make([]string, 0, len(values))
+	for _, v := range values {
+		patterns = append(patterns, regexp.QuoteMeta(v))
+	}
+	return metric + "{" + label + "=~" + EscapeLabelValue(strings.Join(patterns, "|")) + "}"
+}
@@ -0,0 +1,54 @@
+package prom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{`firing`, `"firing"`},
+		{`foo"} or vector(1) or ALERTS{a="`, `"foo\"} or vector(1) or ALERTS{a=\""`},
+		{`C:\path\`, `"C:\\path\\"`},
+		{".*|(foo)+[bar]?^$", `".*|(foo)+[bar]?^$"`},
+		{"multi\nline", `"multi\nline"`},
+	}
+
+	for _, tt := range tests {
+		escaped := EscapeLabelValue(tt.value)
+		assert.Equal(t, tt.expected, escaped)
+
+		// The literal must decode back to the original value.
+		unquoted, err := strconv.Unquote(escaped)
+		require.NoError(t, err)
+		assert.Equal(t, tt.value, unquoted)
+	}
+}
+
+func TestBuildSelector(t *testing.T) {
+	assert.Equal(t, `ALERTS{alertstate="firing"}`,
+		BuildSelector("ALERTS", map[string]string{"alertstate": "firing"}))
+
+	assert.Equal(t, `ALERTS{alertname="Foo\"}",group_id="a\\b",namespace=".*"}`,
+		BuildSelector("ALERTS", map[string]string{
+			"namespace": ".*",
+			"group_id":  `a\b`,
+			"alertname": `Foo"}`,
+		}))
+
+	assert.Equal(t, `up{}`, BuildSelector("up", nil))
+}
+
+func TestBuildSelectorAny(t *testing.T) {
+	assert.Equal(t, `cluster_operator_conditions{condition=~"Available|Degraded"}`,
+		BuildSelectorAny("cluster_operator_conditions", "condition", []string{"Available", "Degraded"}))
+
+	assert.Equal(t, `ALERTS{alertname=~"Foo\\.\\*|\"Bar\""}`,
+		BuildSelectorAny("ALERTS", "alertname", []string{"Foo.*", `"Bar"`}))
+}
@@ -35,7 +35,7 @@ func PromMetricToString(m PromMetric) string {
 			ret += ", "
 		}
 
-		ret += k + `="` + v + `"`
+		ret += k + "=" + EscapeLabelValue(v)
 	}
 	ret += "}"
 	return ret
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+var clusterOperatorResource = schema.GroupVersionResource{
+	Group: "config.openshift.io", Version: "v1", Resource: "clusteroperators",
+}
+
+// degradedGracePeriod is how long the processing has to keep failing
+// before the ClusterOperator is reported as degraded, so that transient
+// errors (e.g. a Prometheus restart) are retried silently.
+const degradedGracePeriod = 5 * time.Minute
+
+// ClusterOperatorReporter reflects the status of the analyzer itself into
+// the conditions of its ClusterOperator, so that it participates in
+// the cluster status reporting.
+type ClusterOperatorReporter struct {
+	client dynamic.Interface
+	name   string
+
+	// failingSince is the time the processing was first reported failing,
+	// zero when it succeeds.
+	failingSince time.Time
+}
+
+// NewClusterOperatorReporter creates a reporter writing to the ClusterOperator
+// with the given name. The ClusterOperator is created when missing.
+func NewClusterOperatorReporter(client dynamic.Interface, name string) *ClusterOperatorReporter {
+	return &ClusterOperatorReporter{client: client, name: name}
+}
+
+// Run reports the status every interval and blocks until canceled via the ctx.
+func (r *ClusterOperatorReporter) Run(ctx context.Context, interval time.Duration,
+	status func() processor.ProcessingStatus) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Report(ctx, status(), time.Now()); err != nil {
+			slog.Error("Failed to report the cluster operator status", "name", r.name, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report writes the conditions corresponding to the processing status.
+func (r *ClusterOperatorReporter) Report(ctx context.Context, status processor.ProcessingStatus, now time.Time) error {
+	client := r.client.Resource(clusterOperatorResource)
+	obj, err := client.Get(ctx, r.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		obj, err = client.Create(ctx, newClusterOperator(r.name), metav1.CreateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	var co configv1.ClusterOperator
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &co); err != nil {
+		return err
+	}
+
+	if status.LastError == nil {
+		r.failingSince = time.Time{}
+	} else if r.failingSince.IsZero() {
+		r.failingSince = now
+	}
+	degraded := status.LastError != nil && now.Sub(r.failingSince) >= degradedGracePeriod
+	for _, cond := range statusConditions(status, degraded) {
+		setCondition(&co.Status.Conditions, cond, metav1.NewTime(now))
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&co)
+	if err != nil {
+		return err
+	}
+	_, err = client.UpdateStatus(ctx, &unstructured.Unstructured{Object: content}, metav1.UpdateOptions{})
+	return err
+}
+
+func newClusterOperator(name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(configv1.GroupVersion.String())
+	obj.SetKind("ClusterOperator")
+	obj.SetName(name)
+	return obj
+}
+
+// statusConditions maps the processing status to the ClusterOperator conditions.
+//
+// The last error is only reported when degraded, i.e. when the processing
+// keeps failing for longer than the degradedGracePeriod.
+func statusConditions(status processor.ProcessingStatus, degraded bool) []configv1.ClusterOperatorStatusCondition {
+	available := configv1.ClusterOperatorStatusCondition{
+		Type:    configv1.OperatorAvailable,
+		Status:  configv1.ConditionTrue,
+		Reason:  "AsExpected",
+		Message: "The alerts are being processed",
+	}
+	if status.LastSuccess.IsZero() {
+		available.Status = configv1.ConditionFalse
+		available.Reason = "NotProcessed"
+		available.Message = "No alerts processing succeeded yet"
+	}
+
+	degradedCond := configv1.ClusterOperatorStatusCondition{
+		Type:   configv1.OperatorDegraded,
+		Status: configv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if degraded {
+		degradedCond.Status = configv1.ConditionTrue
+		degradedCond.Reason = "ProcessingFailed"
+		degradedCond.Message = status.LastError.Error()
+	}
+
+	progressing := configv1.ClusterOperatorStatusCondition{
+		Type:   configv1.OperatorProgressing,
+		Status: configv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	return []configv1.ClusterOperatorStatusCondition{available, degradedCond, progressing}
+}
+
+// setCondition sets the condition, keeping its transition time when
+// the status doesn't change.
+func setCondition(conditions *[]configv1.ClusterOperatorStatusCondition,
+	cond configv1.ClusterOperatorStatusCondition, now metav1.Time) {
+	cond.LastTransitionTime = now
+	for i, existing := range *conditions {
+		if existing.Type != cond.Type {
+			continue
+		}
+		if existing.Status == cond.Status {
+			cond.LastTransitionTime = existing.LastTransitionTime
+		}
+		(*conditions)[i] = cond
+		return
+	}
+	*conditions = append(*conditions, cond)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+// TestClusterOperatorReporter tests the processing status is written
+// to the ClusterOperator conditions.
+func TestClusterOperatorReporter(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{clusterOperatorResource: "ClusterOperatorList"})
+	reporter := NewClusterOperatorReporter(client, "cluster-health-analyzer")
+	ctx := context.Background()
+
+	conditions := func() map[configv1.ClusterStatusConditionType]configv1.ClusterOperatorStatusCondition {
+		obj, err := client.Resource(clusterOperatorResource).Get(ctx, "cluster-health-analyzer", metav1.GetOptions{})
+		require.NoError(t, err)
+		var co configv1.ClusterOperator
+		require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &co))
+		ret := make(map[configv1.ClusterStatusConditionType]configv1.ClusterOperatorStatusCondition)
+		for _, cond := range co.Status.Conditions {
+			ret[cond.Type] = cond
+		}
+		return ret
+	}
+
+	// The ClusterOperator is created on the first report.
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, reporter.Report(ctx, processor.ProcessingStatus{LastSuccess: start}, start))
+	conds := conditions()
+	assert.Equal(t, configv1.ConditionTrue, conds[configv1.OperatorAvailable].Status)
+	assert.Equal(t, configv1.ConditionFalse, conds[configv1.OperatorDegraded].Status)
+	assert.Equal(t, configv1.ConditionFalse, conds[configv1.OperatorProgressing].Status)
+
+	// The processing fails, which is not reported until it persists.
+	failing := processor.ProcessingStatus{
+		LastSuccess: start,
+		LastError:   errors.New("prometheus unavailable"),
+	}
+	require.NoError(t, reporter.Report(ctx, failing, start.Add(time.Minute)))
+	assert.Equal(t, configv1.ConditionFalse, conditions()[configv1.OperatorDegraded].Status)
+
+	failed := start.Add(time.Minute + degradedGracePeriod)
+	require.NoError(t, reporter.Report(ctx, failing, failed))
+	conds = conditions()
+	assert.Equal(t, configv1.ConditionTrue, conds[configv1.OperatorAvailable].Status)
+	assert.Equal(t, configv1.ConditionTrue, conds[configv1.OperatorDegraded].Status)
+	assert.Equal(t, "prometheus unavailable", conds[configv1.OperatorDegraded].Message)
+	// Only the changed conditions transition.
+	assert.True(t, conds[configv1.OperatorDegraded].LastTransitionTime.Time.Equal(failed))
+	assert.True(t, conds[configv1.OperatorAvailable].LastTransitionTime.Time.Equal(start))
+
+	// A success resets the grace period.
+	recovered := failed.Add(time.Minute)
+	require.NoError(t, reporter.Report(ctx, processor.ProcessingStatus{LastSuccess: recovered}, recovered))
+	assert.Equal(t, configv1.ConditionFalse, conditions()[configv1.OperatorDegraded].Status)
+	require.NoError(t, reporter.Report(ctx, failing, recovered.Add(time.Minute)))
+	assert.Equal(t, configv1.ConditionFalse, conditions()[configv1.OperatorDegraded].Status)
+}
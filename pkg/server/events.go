@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+const (
+	// IncidentsStreamPath is the path of the server-sent events stream
+	// of the incident changes.
+	//
+	// It's kept outside of /api and /apis, which the API server treats as
+	// resource requests, so that it's authorized as a non-resource URL.
+	IncidentsStreamPath = "/incidents/stream"
+
+	// eventsBufferSize is the number of events buffered per subscriber
+	// before it's considered too slow and dropped.
+	eventsBufferSize = 100
+)
+
+// incidentsStreamHandler streams the incident events as server-sent events.
+//
+// The stream ends when the client disconnects or doesn't keep up with
+// the events, in which case the client is expected to reconnect.
+func incidentsStreamHandler(events *processor.IncidentEvents) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		ch, cancel := events.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					slog.Error("Failed to encode incident event", "err", err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
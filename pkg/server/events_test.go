@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+// newFakePrometheus serves the firing alerts returned by the alerts function.
+func newFakePrometheus(t *testing.T, alerts func() string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+
+		var data string
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/query_range"):
+			data = `{"resultType":"matrix","result":[]}`
+		case r.Form.Get("query") == "time()":
+			data = `{"resultType":"scalar","result":[1720000000,"1720000000"]}`
+		default:
+			data = `{"resultType":"vector","result":[` + alerts() + `]}`
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":` + data + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIncidentsStream(t *testing.T) {
+	var firing atomic.Bool
+	firing.Store(true)
+	prom := newFakePrometheus(t, func() string {
+		if !firing.Load() {
+			return ""
+		}
+		return `{"metric":{"__name__":"ALERTS","alertname":"KubePodCrashLooping","alertstate":"firing",` +
+			`"namespace":"openshift-monitoring","severity":"warning"},"value":[1720000000,"1"]}`
+	})
+
+	events := processor.NewIncidentEvents(eventsBufferSize)
+	p, err := processor.NewProcessor(newMetrics(""), processor.ProcessorConfig{
+		PromURL: prom.URL,
+		Events:  events,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, p.InitGroupsCollection(ctx, time.Now().Add(-time.Hour), time.Now(), time.Minute))
+
+	stream := httptest.NewServer(incidentsStreamHandler(events))
+	defer stream.Close()
+
+	resp, err := http.Get(stream.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() processor.IncidentEvent {
+		var event processor.IncidentEvent
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				require.NoError(t, json.Unmarshal([]byte(data), &event))
+				return event
+			}
+		}
+	}
+
+	// The alert starts firing.
+	require.NoError(t, p.Process(ctx))
+	opened := readEvent()
+	assert.Equal(t, processor.IncidentOpened, opened.Type)
+	assert.NotEmpty(t, opened.GroupID)
+	assert.Equal(t, "warning", opened.Severity)
+	assert.Equal(t, []string{"KubePodCrashLooping"}, opened.Alerts)
+	assert.Equal(t, []string{"core/monitoring"}, opened.Components)
+
+	// The alert resolves.
+	firing.Store(false)
+	require.NoError(t, p.Process(ctx))
+	resolved := readEvent()
+	assert.Equal(t, processor.IncidentResolved, resolved.Type)
+	assert.Equal(t, opened.GroupID, resolved.GroupID)
+}
+
+func TestIncidentEventsSlowSubscriber(t *testing.T) {
+	events := processor.NewIncidentEvents(1)
+	ch, cancel := events.Subscribe()
+	defer cancel()
+
+	events.Publish([]processor.IncidentEvent{{GroupID: "1"}, {GroupID: "2"}})
+
+	// The first event is buffered, then the subscriber is dropped.
+	event, ok := <-ch
+	assert.True(t, ok)
+	assert.Equal(t, "1", event.GroupID)
+	_, ok = <-ch
+	assert.False(t, ok)
+}
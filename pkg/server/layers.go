@@ -0,0 +1,28 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+// LayersPath is the path of the summary of the active incidents per layer,
+// outside of /api to be authorized as a non-resource URL.
+const LayersPath = "/layers"
+
+// layersHandler serves the summary of the active incidents per layer as JSON.
+func layersHandler(layers func() []processor.LayerSummary) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		summaries := layers()
+		if summaries == nil {
+			// Not processed yet, report all the layers as unaffected.
+			summaries = processor.SummarizeLayers(nil)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(summaries); err != nil {
+			slog.Error("Failed to encode layers summary", "err", err)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+)
+
+// MappingsPath is the path of the debugging view of the components
+// the current alerts are mapped to.
+const MappingsPath = "/debug/mappings"
+
+// mappingsHandler serves the mappings of the current alerts as JSON.
+func mappingsHandler(mappings func() []processor.AlertMapping) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		m := mappings()
+		if m == nil {
+			// Not processed yet.
+			m = []processor.AlertMapping{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m); err != nil {
+			slog.Error("Failed to encode alert mappings", "err", err)
+		}
+	})
+}
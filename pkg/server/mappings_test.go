@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+func TestMappingsHandler(t *testing.T) {
+	var alerts []prom.Alert
+	handler := mappingsHandler(func() []processor.AlertMapping {
+		if alerts == nil {
+			return nil
+		}
+		return processor.Layers(nil).ExplainMappings(alerts)
+	})
+
+	get := func() []processor.AlertMapping {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MappingsPath, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var mappings []processor.AlertMapping
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &mappings))
+		return mappings
+	}
+
+	// Nothing processed yet.
+	assert.Empty(t, get())
+
+	alerts = []prom.Alert{
+		{Name: "etcdNoLeader", Labels: map[string]string{"alertname": "etcdNoLeader", "namespace": "openshift-etcd"}},
+		{Name: "CustomAlert", Labels: map[string]string{"alertname": "CustomAlert", "namespace": "custom"}},
+	}
+	mappings := get()
+	require.Len(t, mappings, 2)
+	assert.Equal(t, "core", mappings[0].Layer)
+	assert.Equal(t, "etcd", mappings[0].Component)
+	assert.Equal(t, []string{"namespace"}, mappings[0].MatchedKeys)
+	assert.Equal(t, "openshift-etcd", mappings[0].Alert["namespace"])
+	assert.Equal(t, "Others", mappings[1].Component)
+	assert.Empty(t, mappings[1].MatchedKeys)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, MappingsPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
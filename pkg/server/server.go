@@ -21,16 +21,63 @@ const (
 	historyLookback = 4 * 24 * time.Hour
 )
 
-var (
-	healthMapMetrics = prom.NewMetricSet(
-		"cluster:health:components:map",
-		"Cluster health components mapping.",
-	)
-	componentsMetrics = prom.NewMetricSet(
-		"cluster:health:components",
-		"Cluster components and their ranking.",
-	)
-)
+// newMetrics creates the metrics updated by the processor, with their
+// names using the given prefix.
+func newMetrics(prefix string) processor.Metrics {
+	return processor.Metrics{
+		HealthMap: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.ComponentsMapMetric),
+			"Cluster health components mapping.",
+		),
+		Components: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.ComponentsMetric),
+			"Cluster components and their ranking.",
+		),
+		Grouping: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.GroupingStatsMetric),
+			"Statistics about the quality of the alerts grouping.",
+		),
+		Groups: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.GroupsCountMetric),
+			"Number of the incident groups tracked for matching.",
+		),
+		WeightedSeverity: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.WeightedSeverityMetric),
+			"Sum of the severities of the active incidents.",
+		),
+		Suppressed: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.SuppressedAlertsMetric),
+			"Number of firing alerts dropped by the suppression rules.",
+		),
+		Unmapped: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.UnmappedAlertsMetric),
+			"Number of firing alerts not mapped to any component.",
+		),
+		TimeSkew: prom.NewMetricSet(
+			processor.MetricName(prefix, processor.TimeSkewMetric),
+			"Difference between the analyzer clock and the Prometheus clock in seconds.",
+		),
+		GroupsEvicted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: processor.MetricName(prefix, processor.GroupsEvictedMetric),
+			Help: "Number of incident groups evicted due to the groups limit.",
+		}),
+	}
+}
+
+// newRegistry creates a registry with all the metrics.
+func newRegistry(metrics processor.Metrics) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(metrics.HealthMap)
+	reg.MustRegister(metrics.Components)
+	reg.MustRegister(metrics.Grouping)
+	reg.MustRegister(metrics.Groups)
+	reg.MustRegister(metrics.WeightedSeverity)
+	reg.MustRegister(metrics.Suppressed)
+	reg.MustRegister(metrics.Unmapped)
+	reg.MustRegister(metrics.TimeSkew)
+	reg.MustRegister(metrics.GroupsEvicted)
+	return reg
+}
 
 // Server is the interface for serving the metrics.
 type Server interface {
@@ -42,11 +89,18 @@ type Server interface {
 }
 
 // StartServer starts processing the metrics and serving them
-// on the /metrics endpoint.
-func StartServer(interval time.Duration, prometheusURL string, server Server) {
+// on the /metrics endpoint, together with the incident events stream
+// and the layers summary.
+//
+// The status of the processing is reported to the ClusterOperator when
+// the reporter is set.
+func StartServer(cfg processor.ProcessorConfig, server Server, reporter *ClusterOperatorReporter) {
 	slog.Info("Starting server")
 
-	processor, err := processor.NewProcessor(healthMapMetrics, componentsMetrics, interval, prometheusURL)
+	metrics := newMetrics(cfg.MetricsPrefix)
+	events := processor.NewIncidentEvents(eventsBufferSize)
+	cfg.Events = events
+	processor, err := processor.NewProcessor(metrics, cfg)
 	if err != nil {
 		slog.Error("Failed to create processor, terminating", "err", err)
 		return
@@ -62,15 +116,21 @@ func StartServer(interval time.Duration, prometheusURL string, server Server) {
 	}
 
 	processor.Start(context.Background())
+	if reporter != nil {
+		go reporter.Run(context.Background(), cfg.Interval, processor.Status)
+	}
 
-	reg := prometheus.NewRegistry()
-	reg.MustRegister(healthMapMetrics)
-	reg.MustRegister(componentsMetrics)
+	reg := newRegistry(metrics)
 
 	slog.Info("Serving metrics")
 
 	server.Handle("/metrics",
 		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	server.Handle(IncidentsStreamPath, incidentsStreamHandler(events))
+	server.Handle(LayersPath, layersHandler(processor.Layers))
+	if cfg.DebugMappings {
+		server.Handle(MappingsPath, mappingsHandler(processor.Mappings))
+	}
 
 	err = server.Start(context.Background())
 	if err != nil {
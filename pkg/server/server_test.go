@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/cluster-health-analyzer/pkg/processor"
+	"github.com/openshift/cluster-health-analyzer/pkg/prom"
+)
+
+// TestNewMetricsPrefix tests the exported metrics use the configured prefix,
+// consistently with the names the processor queries.
+func TestNewMetricsPrefix(t *testing.T) {
+	for prefix, expected := range map[string]string{
+		"":               "cluster:health:components:map",
+		"acme:health:":   "acme:health:components:map",
+		"custom_prefix_": "custom_prefix_components:map",
+	} {
+		metrics := newMetrics(prefix)
+		metrics.HealthMap.Update([]prom.Metric{{Labels: map[string]string{"component": "etcd"}, Value: 1}})
+
+		families, err := newRegistry(metrics).Gather()
+		require.NoError(t, err)
+
+		names := make([]string, 0, len(families))
+		for _, f := range families {
+			names = append(names, f.GetName())
+		}
+		assert.Contains(t, names, expected)
+		assert.Contains(t, names, processor.MetricName(prefix, processor.GroupsEvictedMetric))
+		assert.Equal(t, expected, processor.MetricName(prefix, processor.ComponentsMapMetric))
+	}
+}
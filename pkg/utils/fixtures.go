@@ -14,8 +14,8 @@ import (
 // as [prom.Range] or [processor.Interval].
 type RelativeInterval struct {
 	Labels map[string]string
-	Start  int // relative start in minutes
-	End    int // relative end in minutes
+	Start  float64 // relative start in minutes
+	End    float64 // relative end in minutes
 }
 
 // ToRange converts the relative interval to a prom.Range.
@@ -23,9 +23,9 @@ type RelativeInterval struct {
 // The origin is used to calculate the absolute timestamps in the samples.
 func RelativeIntervalToRange(ri RelativeInterval, origin model.Time, step time.Duration) prom.Range {
 	samples := make([]model.SamplePair, 0)
-	for i := ri.Start; i < ri.End; i += int(step.Minutes()) {
+	for i := ri.Start; i < ri.End; i += step.Minutes() {
 		samples = append(samples, model.SamplePair{
-			Timestamp: origin.Add(time.Duration(i) * time.Minute),
+			Timestamp: origin.Add(time.Duration(i * float64(time.Minute))),
 			Value:     1})
 	}
 	return prom.Range{